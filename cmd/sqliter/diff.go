@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/darianmavgo/sqliter/sqliter"
+)
+
+// runDiff implements "sqliter diff", comparing two database files.
+func runDiff(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	schema := fs.Bool("schema", false, "compare table, column, index, view and trigger definitions")
+	migration := fs.Bool("sql", false, "with --schema, print a migration script turning a.db into b.db")
+	data := fs.Bool("data", false, "compare the rows of two tables, given as a.db[/table] b.db[/table]")
+	key := fs.String("key", "", "with --data, comma-separated key columns (default: primary key of a)")
+	format := fs.String("format", "jsonl", "with --data, output format: jsonl or csv")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sqliter diff --schema [--sql] a.db b.db")
+		fmt.Fprintln(fs.Output(), "       sqliter diff --data [--key cols] [--format jsonl|csv] a.db[/table] b.db[/table]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || *schema == *data {
+		fs.Usage()
+		return fmt.Errorf("diff needs either --schema or --data, and two databases")
+	}
+
+	paths := make([]string, 2)
+	for i, p := range fs.Args() {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		paths[i] = abs
+	}
+
+	if *data {
+		opts := sqliter.DataDiffOptions{A: paths[0], B: paths[1]}
+		if *key != "" {
+			opts.Key = strings.Split(*key, ",")
+		}
+		_, err := sqliter.ExportDataDiffFiles(context.Background(), opts, *format, out)
+		return err
+	}
+
+	diff, err := sqliter.DiffSchemaFiles(context.Background(), paths[0], paths[1], sqliter.DiffSchemaOptions{Migration: *migration})
+	if err != nil {
+		return err
+	}
+	if *migration {
+		fmt.Fprint(out, diff.Migration)
+		return nil
+	}
+
+	marks := map[string]string{"added": "+", "removed": "-", "altered": "~"}
+	for _, c := range diff.Changes {
+		name := c.Name
+		if c.Kind == "column" {
+			name = c.Table + "." + c.Name
+		}
+		fmt.Fprintf(out, "%s %s %s\n", marks[c.Change], c.Kind, name)
+		if c.Kind == "column" && c.Change == "altered" {
+			fmt.Fprintf(out, "    %s\n -> %s\n", c.Before, c.After)
+		}
+	}
+	return nil
+}
+
+// exitOnError prints err and exits with a non-zero status.
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sqliter: %v\n", err)
+		os.Exit(1)
+	}
+}
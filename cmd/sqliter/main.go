@@ -22,8 +22,14 @@ func main() {
 			fmt.Printf("sqliter version %s\n", version)
 			return
 		}
+		if os.Args[1] == "diff" {
+			exitOnError(runDiff(os.Args[2:], os.Stdout))
+			return
+		}
 		if os.Args[1] == "--help" {
 			fmt.Println("Usage: sqliter [file or url]")
+			fmt.Println("       sqliter diff --schema [--sql] a.db b.db")
+			fmt.Println("       sqliter diff --data [--key cols] [--format jsonl|csv] a.db[/table] b.db[/table]")
 			fmt.Println("  file: path to local sqlite database file or directory containing database files")
 			fmt.Println("  url:  url to a remote sqlite database file")
 			return
@@ -0,0 +1,392 @@
+package sqliter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// maxWorkloadPatterns bounds the distinct access patterns remembered per table.
+const maxWorkloadPatterns = 200
+
+// accessPattern is the shape of a query against one table: the columns it
+// filters by equality, the first column it filters by range, and its sort column.
+type accessPattern struct {
+	eq      []string
+	rng     string
+	orderBy string
+}
+
+func (p accessPattern) key() string {
+	return strings.Join(p.eq, ",") + "|" + p.rng + "|" + p.orderBy
+}
+
+// patternStats aggregates the executions of one access pattern.
+type patternStats struct {
+	table    string
+	pattern  accessPattern
+	sample   string // SQL of the latest execution, explained by the advisor
+	count    int
+	duration time.Duration
+}
+
+// workload remembers the access patterns of executed queries, per database and table.
+type workload struct {
+	mu     sync.Mutex
+	tables map[string]map[string]map[string]*patternStats // db -> table -> pattern key
+}
+
+func newWorkload() *workload {
+	return &workload{tables: make(map[string]map[string]map[string]*patternStats)}
+}
+
+// observeQuery records the access pattern of an executed query. It only inspects
+// the query text, since the database connection may still be busy with its rows.
+func (e *Engine) observeQuery(pq *preparedQuery, elapsed time.Duration) {
+	p := parseAccessPattern(pq.bq.Where)
+	p.orderBy = pq.bq.OrderBy
+	if len(p.eq) == 0 && p.rng == "" && p.orderBy == "" {
+		return
+	}
+
+	w := e.workload
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.tables[pq.dbRelPath] == nil {
+		w.tables[pq.dbRelPath] = make(map[string]map[string]*patternStats)
+	}
+	patterns := w.tables[pq.dbRelPath][pq.bq.Table]
+	if patterns == nil {
+		patterns = make(map[string]*patternStats)
+		w.tables[pq.dbRelPath][pq.bq.Table] = patterns
+	}
+	stats, ok := patterns[p.key()]
+	if !ok {
+		if len(patterns) >= maxWorkloadPatterns {
+			return
+		}
+		stats = &patternStats{table: pq.bq.Table, pattern: p}
+		patterns[p.key()] = stats
+	}
+	stats.sample = pq.query
+	stats.count++
+	stats.duration += elapsed
+}
+
+// parseAccessPattern finds the columns compared in a WHERE clause, as written by
+// Banquet and BuildWhereClause: an identifier followed by =, IN or IS is an
+// equality, one followed by <, <=, >, >= or BETWEEN is a range. Identifiers are
+// validated against the table later, so keywords and functions picked up here are harmless.
+func parseAccessPattern(where string) accessPattern {
+	var p accessPattern
+	tokens := sqlTokens(where)
+	for i := 0; i+1 < len(tokens); i++ {
+		t := tokens[i]
+		if !t.ident {
+			continue
+		}
+		op := strings.ToUpper(tokens[i+1].text)
+		if op == "IS" && i+2 < len(tokens) && strings.ToUpper(tokens[i+2].text) == "NOT" {
+			continue
+		}
+		if i > 0 && strings.ToUpper(tokens[i-1].text) == "NOT" {
+			continue
+		}
+		switch op {
+		case "=", "==", "IN", "IS":
+			if !containsFold(p.eq, t.text) {
+				p.eq = append(p.eq, t.text)
+			}
+		case "<", "<=", ">", ">=", "BETWEEN":
+			if p.rng == "" {
+				p.rng = t.text
+			}
+		}
+	}
+	sort.Strings(p.eq)
+	if containsFold(p.eq, p.rng) {
+		p.rng = ""
+	}
+	return p
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type sqlToken struct {
+	text  string
+	ident bool // Bare or quoted identifier, unquoted in text
+}
+
+// sqlTokens splits SQL into identifiers, operators and other words, dropping string literals.
+func sqlTokens(s string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' || c == ',':
+			i++
+		case c == '\'':
+			j := i + 1
+			for j < len(s) {
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: "'"})
+			i = j + 1
+		case c == '"' || c == '`' || c == '[':
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			j := strings.IndexByte(s[i+1:], closer)
+			if j == -1 {
+				return tokens
+			}
+			name := s[i+1 : i+1+j]
+			if c == '"' {
+				// QuoteIdentifier doubles embedded quotes
+				for i+2+j < len(s) && s[i+2+j] == '"' {
+					k := strings.IndexByte(s[i+3+j:], '"')
+					if k == -1 {
+						break
+					}
+					name += `"` + s[i+3+j:i+3+j+k]
+					j += k + 2
+				}
+			}
+			tokens = append(tokens, sqlToken{text: name, ident: true})
+			i += j + 2
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			word := s[i:j]
+			keyword := strings.ToUpper(word)
+			ident := keyword != "IN" && keyword != "IS" && keyword != "NOT" && keyword != "BETWEEN" && keyword != "AND" && keyword != "OR" && keyword != "LIKE" && keyword != "NULL"
+			tokens = append(tokens, sqlToken{text: word, ident: ident})
+			i = j
+		case strings.ContainsRune("<>=!", rune(c)):
+			j := i + 1
+			if j < len(s) && strings.ContainsRune("<>=", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: s[i:j]})
+			i = j
+		default:
+			j := i + 1
+			for j < len(s) && !strings.ContainsRune(" \t\n\r(),'\"`[<>=!", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens
+}
+
+// IndexSuggestion is a proposed index with the workload it would serve.
+type IndexSuggestion struct {
+	Name          string   `json:"name"`
+	Table         string   `json:"table"`
+	Columns       []string `json:"columns"`
+	SQL           string   `json:"sql"`
+	Queries       int      `json:"queries"`       // Executions that would use the index
+	AvgDurationMs float64  `json:"avgDurationMs"` // Their average duration
+	TableRows     int64    `json:"tableRows"`     // Estimated rows scanned per query without the index
+	Reasons       []string `json:"reasons"`       // Plan steps the index would remove
+	Score         float64  `json:"score"`         // Ranking: total time spent in the queries, weighted by plan cost
+}
+
+// indexNameUnsafe matches the runs of characters replaced in index names, so
+// that names stay plain identifiers.
+var indexNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// indexName builds the name of a suggested index.
+func indexName(table string, cols []string) string {
+	name := "sqliter_idx_" + table + "_" + strings.Join(cols, "_")
+	return indexNameUnsafe.ReplaceAllString(name, "_")
+}
+
+// indexColumns orders a pattern's columns for an index: equality columns first,
+// then the sort column, or the range column when there is no sort.
+func (p accessPattern) indexColumns(columns []string) []string {
+	var cols []string
+	add := func(c string) {
+		for _, real := range columns {
+			if strings.EqualFold(real, c) && !containsString(cols, real) {
+				cols = append(cols, real)
+			}
+		}
+	}
+	for _, c := range p.eq {
+		add(c)
+	}
+	if p.orderBy != "" {
+		add(p.orderBy)
+	} else if p.rng != "" {
+		add(p.rng)
+	}
+	return cols
+}
+
+// AdviseIndexes ranks CREATE INDEX suggestions for a database from the queries
+// this engine has executed against it. Each access pattern's latest query is
+// explained; patterns whose plan already uses an index without sorting are skipped.
+func (e *Engine) AdviseIndexes(ctx context.Context, dbRelPath string) ([]IndexSuggestion, error) {
+	dbRelPath = strings.TrimPrefix(dbRelPath, "/")
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+
+	e.workload.mu.Lock()
+	var observed []patternStats
+	for _, patterns := range e.workload.tables[dbRelPath] {
+		for _, s := range patterns {
+			observed = append(observed, *s)
+		}
+	}
+	e.workload.mu.Unlock()
+
+	columns := make(map[string][]string)
+	for _, s := range observed {
+		if _, ok := columns[s.table]; ok {
+			continue
+		}
+		if columns[s.table], err = tableColumns(ctx, db, s.table); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+	}
+
+	byName := make(map[string]*IndexSuggestion)
+	var totalMs = make(map[string]float64)
+	for _, s := range observed {
+		plan, err := explainQuery(ctx, db, s.sample)
+		if err != nil {
+			continue // Table dropped or changed since the query ran
+		}
+		table := s.table
+		cols := s.pattern.indexColumns(columns[table])
+		if len(cols) == 0 {
+			continue
+		}
+
+		weight, reasons := 0.0, []string{}
+		for _, f := range plan.Flags {
+			if f.Table != "" && f.Table != table {
+				continue // A step of a subquery on another table
+			}
+			switch f.Kind {
+			case "full_scan":
+				weight += 1
+			case "temp_btree", "automatic_index":
+				weight += 0.5
+			default:
+				continue
+			}
+			reasons = append(reasons, f.Detail)
+		}
+		if weight == 0 {
+			continue
+		}
+
+		name := indexName(table, cols)
+		sug, ok := byName[name]
+		if !ok {
+			sug = &IndexSuggestion{
+				Name:    name,
+				Table:   table,
+				Columns: cols,
+				SQL:     createIndexSQL(name, table, cols),
+			}
+			byName[name] = sug
+		}
+		sug.Queries += s.count
+		ms := float64(s.duration.Microseconds()) / 1000
+		totalMs[name] += ms
+		sug.Score += ms * weight
+		for _, r := range reasons {
+			if !containsString(sug.Reasons, r) {
+				sug.Reasons = append(sug.Reasons, r)
+			}
+		}
+	}
+
+	suggestions := make([]IndexSuggestion, 0, len(byName))
+	for name, sug := range byName {
+		sug.AvgDurationMs = totalMs[name] / float64(sug.Queries)
+		sug.TableRows, _ = estimateTableRows(ctx, db, sug.Table)
+		suggestions = append(suggestions, *sug)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Name < suggestions[j].Name
+	})
+	return suggestions, nil
+}
+
+func createIndexSQL(name, table string, cols []string) string {
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = sqlite.QuoteIdentifier(c)
+	}
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+		sqlite.QuoteIdentifier(name), sqlite.QuoteIdentifier(table), strings.Join(quoted, ", "))
+}
+
+// ApplyIndex creates an index on the given table columns, as suggested by AdviseIndexes.
+// Columns are validated against the table so no client SQL is executed. It
+// returns ErrReadOnly unless Config.Writable is on.
+func (e *Engine) ApplyIndex(ctx context.Context, dbRelPath, table string, columns []string) (*IndexSuggestion, error) {
+	if err := e.checkWritable(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("columns required")
+	}
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if len(existing) == 0 {
+		return nil, fmt.Errorf("no such table: %s", table)
+	}
+	for _, c := range columns {
+		if !containsString(existing, c) {
+			return nil, fmt.Errorf("no such column: %s", c)
+		}
+	}
+
+	name := indexName(table, columns)
+	sug := &IndexSuggestion{Name: name, Table: table, Columns: columns, SQL: createIndexSQL(name, table, columns)}
+	if _, err := db.ExecContext(ctx, sug.SQL); err != nil {
+		return nil, fmt.Errorf("error creating index: %w", err)
+	}
+	return sug, nil
+}
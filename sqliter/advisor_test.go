@@ -0,0 +1,152 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestParseAccessPattern(t *testing.T) {
+	tests := []struct {
+		where string
+		want  accessPattern
+	}{
+		{`"customer" = 'x'`, accessPattern{eq: []string{"customer"}}},
+		{`status IN ('a', 'b') AND "total" > 10`, accessPattern{eq: []string{"status"}, rng: "total"}},
+		{`"a""b" = 1 AND region IS NULL`, accessPattern{eq: []string{`a"b`, "region"}}},
+		{`name LIKE '%x = y%' AND NOT flag = 1`, accessPattern{}},
+		{`[total] BETWEEN 1 AND 5 AND total >= 2`, accessPattern{rng: "total"}},
+		{`region IS NOT NULL`, accessPattern{}},
+	}
+	for _, tt := range tests {
+		got := parseAccessPattern(tt.where)
+		if !reflect.DeepEqual(got.eq, tt.want.eq) || got.rng != tt.want.rng {
+			t.Errorf("parseAccessPattern(%q) = %+v, want %+v", tt.where, got, tt.want)
+		}
+	}
+}
+
+func TestIndexName(t *testing.T) {
+	if got := indexName("order items", []string{"unit-price", "qty"}); got != "sqliter_idx_order_items_unit_price_qty" {
+		t.Errorf("Unexpected index name %q", got)
+	}
+}
+
+func TestAdviseIndexes(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL);
+		INSERT INTO orders (customer, total) VALUES ('ada', 10), ('bob', 20), ('ada', 30);
+	`)
+
+	cfg := &Config{ServeFolder: tmpDir}
+	engine := NewEngine(cfg)
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	suggestions, err := engine.AdviseIndexes(ctx, "shop.db")
+	if err != nil {
+		t.Fatalf("AdviseIndexes failed: %v", err)
+	}
+	if len(suggestions) != 0 {
+		t.Fatalf("Expected no suggestions before any query, got %+v", suggestions)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/orders", FilterWhere: `"customer" = 'ada'`, SortCol: "total"}); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+	}
+	// Queries by primary key are already indexed
+	if _, err := engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/orders", FilterWhere: "id = 1"}); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	suggestions, err = engine.AdviseIndexes(ctx, "/shop.db")
+	if err != nil {
+		t.Fatalf("AdviseIndexes failed: %v", err)
+	}
+	if len(suggestions) != 1 {
+		t.Fatalf("Expected 1 suggestion, got %+v", suggestions)
+	}
+	sug := suggestions[0]
+	if sug.Table != "orders" || !reflect.DeepEqual(sug.Columns, []string{"customer", "total"}) {
+		t.Errorf("Expected an index on orders(customer, total), got %+v", sug)
+	}
+	if sug.Queries != 3 || sug.TableRows != 3 || len(sug.Reasons) == 0 {
+		t.Errorf("Unexpected suggestion stats: %+v", sug)
+	}
+
+	if _, err := engine.ApplyIndex(ctx, "shop.db", "orders", sug.Columns); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected ApplyIndex to be refused when read-only, got %v", err)
+	}
+	cfg.Writable = true
+	if _, err := engine.ApplyIndex(ctx, "shop.db", "orders", []string{"nope"}); err == nil {
+		t.Error("Expected ApplyIndex to reject an unknown column")
+	}
+	applied, err := engine.ApplyIndex(ctx, "shop.db", "orders", sug.Columns)
+	if err != nil {
+		t.Fatalf("ApplyIndex failed: %v", err)
+	}
+	if applied.Name != sug.Name {
+		t.Errorf("Expected index %s, got %s", sug.Name, applied.Name)
+	}
+
+	suggestions, err = engine.AdviseIndexes(ctx, "shop.db")
+	if err != nil {
+		t.Fatalf("AdviseIndexes failed: %v", err)
+	}
+	if len(suggestions) != 0 {
+		t.Errorf("Expected no suggestions once the index exists, got %+v", suggestions)
+	}
+}
+
+func TestAPIAdvisor(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL);
+	`)
+
+	cfg := &Config{ServeFolder: tmpDir}
+	srv := NewServer(cfg)
+	defer srv.engine.CloseAll()
+
+	if _, err := srv.engine.Query(context.Background(), QueryOptions{BanquetPath: "/shop.db/orders", FilterWhere: `"customer" = 'x'`}); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/advisor?db=shop.db", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var suggestions []IndexSuggestion
+	if err := json.Unmarshal(w.Body.Bytes(), &suggestions); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Columns[0] != "customer" {
+		t.Fatalf("Expected a suggestion on customer, got %+v", suggestions)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"db": "shop.db", "table": "orders", "columns": suggestions[0].Columns})
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/advisor", bytes.NewReader(body)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 on a read-only server, got %d", w.Code)
+	}
+
+	cfg.Writable = true
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/advisor", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -15,6 +15,9 @@ type AgFilter struct {
 	Filter     interface{} `json:"filter"`   // Can be string or number
 	FilterTo   interface{} `json:"filterTo"` // For inRange
 
+	// For set filters
+	Values []interface{} `json:"values"`
+
 	// For complex filters
 	Operator   string    `json:"operator"`
 	Condition1 *AgFilter `json:"condition1"`
@@ -33,6 +36,11 @@ func BuildWhereClause(filterModelJSON string) (string, error) {
 		return "", err
 	}
 
+	return buildWhereFromModel(model)
+}
+
+// buildWhereFromModel translates a decoded filter model into a SQL WHERE fragment.
+func buildWhereFromModel(model AgFilterModel) (string, error) {
 	var conditions []string
 	for col, filter := range model {
 		cond, err := buildCondition(col, filter)
@@ -95,6 +103,8 @@ func buildCondition(col string, filter AgFilter) (string, error) {
 		default:
 			return "", fmt.Errorf("unsupported text filter type: %s", filter.Type)
 		}
+	} else if filter.FilterType == "set" {
+		return buildSetCondition(colEscaped, filter.Values)
 	} else if filter.FilterType == "number" {
 		val, err := validateNumber(filter.Filter)
 		if err != nil {
@@ -125,7 +135,38 @@ func buildCondition(col string, filter AgFilter) (string, error) {
 		}
 	}
 
-	return "", nil // Ignore other types (date, set) for now
+	return "", nil // Ignore other types (date) for now
+}
+
+// buildSetCondition matches any of the selected set filter values. AG Grid sends
+// null for the "(Blanks)" entry, which must be matched with IS NULL.
+func buildSetCondition(colEscaped string, values []interface{}) (string, error) {
+	if len(values) == 0 {
+		return "0", nil // Nothing selected matches nothing
+	}
+
+	var literals []string
+	hasNull := false
+	for _, v := range values {
+		if v == nil {
+			hasNull = true
+			continue
+		}
+		lit, err := sqlLiteral(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid set filter value: %w", err)
+		}
+		literals = append(literals, lit)
+	}
+
+	var parts []string
+	if len(literals) > 0 {
+		parts = append(parts, fmt.Sprintf("%s IN (%s)", colEscaped, strings.Join(literals, ", ")))
+	}
+	if hasNull {
+		parts = append(parts, colEscaped+" IS NULL")
+	}
+	return strings.Join(parts, " OR "), nil
 }
 
 func validateNumber(v interface{}) (string, error) {
@@ -58,6 +58,21 @@ func TestBuildWhereClause(t *testing.T) {
 			filterJSON: ``,
 			expected:   ``,
 		},
+		{
+			name:       "Set Filter",
+			filterJSON: `{"status": {"filterType": "set", "values": ["open", "it's", null]}}`,
+			expected:   `"status" IN ('open', 'it''s') OR "status" IS NULL`,
+		},
+		{
+			name:       "Set Filter Numbers",
+			filterJSON: `{"qty": {"filterType": "set", "values": [1, 2.5, true]}}`,
+			expected:   `"qty" IN (1, 2.5, 1)`,
+		},
+		{
+			name:       "Set Filter Empty",
+			filterJSON: `{"status": {"filterType": "set", "values": []}}`,
+			expected:   `0`,
+		},
 		{
 			name:        "Invalid JSON",
 			filterJSON:  `{invalid`,
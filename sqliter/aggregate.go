@@ -0,0 +1,159 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/darianmavgo/banquet"
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// Aggregate is one aggregate column computed per group, e.g. sum(amount).
+type Aggregate struct {
+	Func   string `json:"func"`            // count, sum, avg, min, max or group_concat
+	Column string `json:"column"`          // Column to aggregate; "" or "*" with count counts rows
+	Alias  string `json:"alias,omitempty"` // Result column name; defaults to func_column
+}
+
+var aggregateFuncs = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true, "group_concat": true,
+}
+
+// ParseAggregate parses the "func(column)" form used in the rows API, e.g. "sum(amount)" or "count(*)".
+func ParseAggregate(s string) (Aggregate, error) {
+	s = strings.TrimSpace(s)
+	open := strings.Index(s, "(")
+	if open == -1 || !strings.HasSuffix(s, ")") {
+		return Aggregate{}, fmt.Errorf("invalid aggregate %q, expected func(column)", s)
+	}
+	agg := Aggregate{
+		Func:   strings.ToLower(strings.TrimSpace(s[:open])),
+		Column: strings.TrimSpace(s[open+1 : len(s)-1]),
+	}
+	return agg, agg.validate()
+}
+
+func (a Aggregate) validate() error {
+	if !aggregateFuncs[strings.ToLower(a.Func)] {
+		return fmt.Errorf("unsupported aggregate function: %s", a.Func)
+	}
+	if (a.Column == "" || a.Column == "*") && strings.ToLower(a.Func) != "count" {
+		return fmt.Errorf("aggregate %s requires a column", a.Func)
+	}
+	return nil
+}
+
+// name returns the result column name of the aggregate.
+func (a Aggregate) name() string {
+	if a.Alias != "" {
+		return a.Alias
+	}
+	if a.Column == "" || a.Column == "*" {
+		return strings.ToLower(a.Func)
+	}
+	return strings.ToLower(a.Func) + "_" + a.Column
+}
+
+// expr returns the SQL expression of the aggregate.
+func (a Aggregate) expr() string {
+	fn := strings.ToUpper(a.Func)
+	if a.Column == "" || a.Column == "*" {
+		return fn + "(*)"
+	}
+	return fmt.Sprintf("%s(%s)", fn, sqlite.QuoteIdentifier(a.Column))
+}
+
+// applyGroupKeys restricts bq.Where to the group selected by keys, which hold
+// values for the leading GroupBy columns. A nil key selects the NULL group.
+func applyGroupKeys(bq *banquet.Banquet, groupBy []string, keys []interface{}) error {
+	if len(keys) > len(groupBy) {
+		return fmt.Errorf("%d group keys given for %d group columns", len(keys), len(groupBy))
+	}
+	for i, key := range keys {
+		col := sqlite.QuoteIdentifier(groupBy[i])
+		cond := col + " IS NULL"
+		if key != nil {
+			lit, err := sqlLiteral(key)
+			if err != nil {
+				return fmt.Errorf("invalid group key: %w", err)
+			}
+			cond = col + " = " + lit
+		}
+		if bq.Where != "" {
+			bq.Where = fmt.Sprintf("(%s) AND (%s)", bq.Where, cond)
+		} else {
+			bq.Where = cond
+		}
+	}
+	return nil
+}
+
+// checkAggregateColumns verifies that the group and aggregate columns exist in
+// table, as SQLite reads an unknown double-quoted name as a string literal.
+func checkAggregateColumns(ctx context.Context, db *sql.DB, table string, groupBy []string, aggs []Aggregate) error {
+	cols, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	names := append([]string{}, groupBy...)
+	for _, a := range aggs {
+		names = append(names, a.Column)
+	}
+	for _, c := range names {
+		if c != "" && c != "*" && !containsString(cols, c) {
+			return fmt.Errorf("no such column: %s", c)
+		}
+	}
+	return nil
+}
+
+// composeAggregate builds a GROUP BY query over groupCols with the given aggregates,
+// keeping the WHERE, ORDER BY and LIMIT/OFFSET of bq. Without aggregates, rows are counted.
+func composeAggregate(bq *banquet.Banquet, groupCols []string, aggs []Aggregate) (query, countQuery string, err error) {
+	if len(aggs) == 0 {
+		aggs = []Aggregate{{Func: "count"}}
+	}
+
+	quotedGroup := make([]string, len(groupCols))
+	for i, c := range groupCols {
+		quotedGroup[i] = sqlite.QuoteIdentifier(c)
+	}
+	selects := append([]string{}, quotedGroup...)
+	for _, a := range aggs {
+		if err := a.validate(); err != nil {
+			return "", "", err
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %s", a.expr(), sqlite.QuoteIdentifier(a.name())))
+	}
+
+	from := "FROM " + sqlite.QuoteIdentifier(bq.Table)
+	if bq.Where != "" {
+		from += " WHERE " + bq.Where
+	}
+	if bq.Having != "" {
+		from += " GROUP BY " + strings.Join(quotedGroup, ", ") + " HAVING " + bq.Having
+	} else {
+		from += " GROUP BY " + strings.Join(quotedGroup, ", ")
+	}
+
+	query = fmt.Sprintf("SELECT %s %s", strings.Join(selects, ", "), from)
+	if bq.OrderBy != "" {
+		query += " ORDER BY " + sqlite.QuoteIdentifier(bq.OrderBy)
+		if bq.SortDirection != "" {
+			query += " " + bq.SortDirection
+		}
+	} else {
+		query += " ORDER BY " + strings.Join(quotedGroup, ", ")
+	}
+	if bq.Limit != "" {
+		query += " LIMIT " + bq.Limit
+	}
+	if bq.Offset != "" {
+		query += " OFFSET " + bq.Offset
+	}
+
+	countQuery = fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 %s)", from)
+	return query, countQuery, nil
+}
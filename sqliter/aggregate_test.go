@@ -0,0 +1,133 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestParseAggregate(t *testing.T) {
+	agg, err := ParseAggregate("SUM(amount)")
+	if err != nil || agg.Func != "sum" || agg.Column != "amount" || agg.name() != "sum_amount" {
+		t.Errorf("Unexpected parse result: %+v, %v", agg, err)
+	}
+	if agg, err := ParseAggregate("count(*)"); err != nil || agg.name() != "count" {
+		t.Errorf("Unexpected parse result: %+v, %v", agg, err)
+	}
+	for _, bad := range []string{"sum", "median(x)", "sum(*)", "drop(x)"} {
+		if _, err := ParseAggregate(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestQueryAggregation(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "sales.db"), `
+		CREATE TABLE sales (region TEXT, product TEXT, amount INTEGER);
+		INSERT INTO sales VALUES
+			('east', 'apples', 10), ('east', 'apples', 5), ('east', 'pears', 7),
+			('west', 'apples', 3), (NULL, 'plums', 1);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	res, err := engine.Query(ctx, QueryOptions{
+		BanquetPath: "/sales.db/sales",
+		GroupBy:     []string{"region", "product"},
+		Aggregates:  []Aggregate{{Func: "sum", Column: "amount"}, {Func: "count"}},
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(res.Columns) != 4 || res.Columns[2] != "sum_amount" || res.TotalCount != 4 {
+		t.Fatalf("Unexpected result: %+v", res)
+	}
+	// Groups are ordered by group columns; NULL region sorts first
+	if row := res.Values[1]; row[0] != "east" || row[1] != "apples" || row[2] != int64(15) || row[3] != int64(2) {
+		t.Errorf("Unexpected east/apples group: %v", row)
+	}
+
+	t.Run("Drilldown levels", func(t *testing.T) {
+		opts := QueryOptions{
+			BanquetPath: "/sales.db/sales",
+			GroupBy:     []string{"region", "product"},
+			Drilldown:   true,
+			SortCol:     "count",
+			SortDir:     "DESC",
+		}
+		res, err := engine.Query(ctx, opts)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(res.Values) != 3 || res.Values[0][0] != "east" || res.Values[0][1] != int64(3) {
+			t.Errorf("Expected top-level region groups, got %v", res.Values)
+		}
+
+		opts.GroupKeys = []interface{}{"east"}
+		res, _ = engine.Query(ctx, opts)
+		if len(res.Values) != 2 || res.Columns[0] != "product" {
+			t.Errorf("Expected product groups within east, got %v %v", res.Columns, res.Values)
+		}
+
+		opts.GroupKeys = []interface{}{"east", "apples"}
+		opts.SortCol = ""
+		res, _ = engine.Query(ctx, opts)
+		if len(res.Values) != 2 || len(res.Columns) != 3 {
+			t.Errorf("Expected the 2 child rows of east/apples, got %v", res.Values)
+		}
+
+		opts.GroupKeys = []interface{}{nil}
+		res, _ = engine.Query(ctx, opts)
+		if len(res.Values) != 1 || res.Values[0][0] != "plums" {
+			t.Errorf("Expected the NULL region group, got %v", res.Values)
+		}
+	})
+
+	t.Run("Unknown columns", func(t *testing.T) {
+		for _, opts := range []QueryOptions{
+			{BanquetPath: "/sales.db/sales", GroupBy: []string{"regoin"}},
+			{BanquetPath: "/sales.db/sales", GroupBy: []string{"region"}, Aggregates: []Aggregate{{Func: "sum", Column: "amonut"}}},
+		} {
+			if _, err := engine.Query(ctx, opts); err == nil || !strings.Contains(err.Error(), "no such column") {
+				t.Errorf("Expected an unknown column error for %+v, got %v", opts, err)
+			}
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		q := url.Values{
+			"path":    {"/sales.db/sales"},
+			"groupBy": {"product"},
+			"agg":     {"max(amount),group_concat(region)"},
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?"+q.Encode(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body QueryResult
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(body.Values) != 3 || body.Columns[1] != "max_amount" || body.Values[0][1] != float64(10) {
+			t.Errorf("Unexpected aggregate response: %+v", body)
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?path=/sales.db/sales&groupBy=product&agg=median(amount)", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for unsupported aggregate, got %d", w.Code)
+		}
+	})
+}
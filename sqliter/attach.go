@@ -0,0 +1,66 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var attachAliasPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedAliases cannot be used to attach databases: SQLite's own schemas and
+// the alias DiffData attaches under.
+var reservedAliases = map[string]bool{"main": true, "temp": true, diffSchemaName: true}
+
+// openAttached opens a connection to dbPath with the databases in attach
+// (alias -> path relative to ServeFolder) available as alias.table. The cached
+// connections are shared by concurrent requests, so attachments get their own
+// connection instead, which the caller closes once its query is done.
+func (e *Engine) openAttached(ctx context.Context, dbPath string, attach map[string]string) (*sql.DB, error) {
+	wanted := make(map[string]string, len(attach))
+	for alias, rel := range attach {
+		if !attachAliasPattern.MatchString(alias) || reservedAliases[strings.ToLower(alias)] {
+			return nil, fmt.Errorf("%w: invalid attach alias: %s", ErrRejected, alias)
+		}
+		path, err := e.resolveDBPath(rel)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			return nil, fmt.Errorf("%w: database not found: %s", ErrRejected, rel)
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+		wanted[alias] = path
+	}
+
+	// The journal mode is left to the cached connection, as switching it here
+	// would wait for that connection's readers
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(10000)&_pragma=cache_size(10000)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+	// Attachments belong to a connection, so the pool must keep its only one
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
+	aliases := make([]string, 0, len(wanted))
+	for alias := range wanted {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if _, err := db.ExecContext(ctx, "ATTACH DATABASE ? AS "+alias, wanted[alias]); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error attaching %s: %w", alias, err)
+		}
+	}
+	return db, nil
+}
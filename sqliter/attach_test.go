@@ -0,0 +1,149 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestCrossDatabaseQuery(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "History.xlsx.db"), `
+		CREATE TABLE sheet1 (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO sheet1 VALUES (1, 'rent'), (2, 'food');
+	`)
+	createTestDB(t, filepath.Join(tmpDir, "sub", "Expenses.csv.db"), `
+		CREATE TABLE data (history_id INTEGER, amount REAL);
+		INSERT INTO data VALUES (1, 500), (1, 20), (2, 7.5);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+	attach := map[string]string{"exp": "sub/Expenses.csv.db"}
+
+	res, err := engine.ExecuteSQL(ctx, SQLOptions{
+		DB:     "History.xlsx.db",
+		SQL:    "SELECT h.name, SUM(e.amount) FROM sheet1 h JOIN exp.data e ON e.history_id = h.id GROUP BY h.name ORDER BY h.name",
+		Attach: attach,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteSQL failed: %v", err)
+	}
+	if len(res.Values) != 2 || res.Values[0][0] != "food" || res.Values[1][1] != 520.0 {
+		t.Fatalf("Unexpected join result: %+v", res.Values)
+	}
+
+	// Banquet queries can reference attached tables in their filters
+	qres, err := engine.Query(ctx, QueryOptions{
+		BanquetPath: "/History.xlsx.db/sheet1",
+		FilterWhere: "id IN (SELECT history_id FROM exp.data WHERE amount > 100)",
+		Attach:      attach,
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(qres.Values) != 1 || qres.Values[0][1] != "rent" {
+		t.Errorf("Expected only rent, got %+v", qres.Values)
+	}
+
+	t.Run("Aliases end with their query", func(t *testing.T) {
+		createTestDB(t, filepath.Join(tmpDir, "Other.db"), `
+			CREATE TABLE data (history_id INTEGER, amount REAL);
+			INSERT INTO data VALUES (2, 1);
+		`)
+		if _, err := engine.ExecuteSQL(ctx, SQLOptions{DB: "History.xlsx.db", SQL: "SELECT COUNT(*) FROM exp.data"}); err == nil {
+			t.Errorf("Expected exp to be detached from queries that do not attach it")
+		}
+		// The same alias can name another file
+		res, err := engine.ExecuteSQL(ctx, SQLOptions{DB: "History.xlsx.db", SQL: "SELECT COUNT(*) FROM exp.data", Attach: map[string]string{"exp": "Other.db"}})
+		if err != nil || res.Values[0][0] != int64(1) {
+			t.Errorf("Expected Other.db under exp, got %+v: %v", res, err)
+		}
+	})
+
+	t.Run("Concurrent queries", func(t *testing.T) {
+		// Queries that attach nothing must not detach exp from one that does
+		var wg sync.WaitGroup
+		errs := make(chan error, 40)
+		for i := 0; i < 20; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := engine.Query(ctx, QueryOptions{
+					BanquetPath: "/History.xlsx.db/sheet1",
+					FilterWhere: "id IN (SELECT history_id FROM exp.data)",
+					Attach:      attach,
+				})
+				errs <- err
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := engine.ExecuteSQL(ctx, SQLOptions{DB: "History.xlsx.db", SQL: "SELECT COUNT(*) FROM sheet1"})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("Concurrent query failed: %v", err)
+			}
+		}
+	})
+
+	t.Run("Sandbox", func(t *testing.T) {
+		for name, opts := range map[string]SQLOptions{
+			"escape":         {DB: "History.xlsx.db", SQL: "SELECT 1", Attach: map[string]string{"x": "../other.db"}},
+			"missing":        {DB: "History.xlsx.db", SQL: "SELECT 1", Attach: map[string]string{"x": "nope.db"}},
+			"reserved alias": {DB: "History.xlsx.db", SQL: "SELECT 1", Attach: map[string]string{"main": "sub/Expenses.csv.db"}},
+			"attach stmt":    {DB: "History.xlsx.db", SQL: "ATTACH '/etc/passwd' AS p"},
+			"write":          {DB: "History.xlsx.db", SQL: "DELETE FROM sheet1"},
+			"cte write":      {DB: "History.xlsx.db", SQL: "WITH x AS (SELECT 1) DELETE FROM sheet1"},
+			"two statements": {DB: "History.xlsx.db", SQL: "SELECT 1; PRAGMA query_only = OFF"},
+		} {
+			if _, err := engine.ExecuteSQL(ctx, opts); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+		if !singleStatement("SELECT ';' -- trailing; comment\n;") {
+			t.Error("Expected quoted and commented semicolons to be ignored")
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		body, _ := json.Marshal(SQLOptions{DB: "History.xlsx.db", SQL: "SELECT COUNT(*) FROM exp.data", Attach: attach, Limit: 1})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/sql", bytes.NewReader(body)))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"values":[[3]]`) {
+			t.Errorf("Unexpected response %d: %s", w.Code, w.Body.String())
+		}
+
+		for sql, code := range map[string]int{
+			"DELETE FROM sheet1":      http.StatusBadRequest,
+			"SELECT * FROM no_such_t": http.StatusInternalServerError,
+		} {
+			body, _ := json.Marshal(SQLOptions{DB: "History.xlsx.db", SQL: sql})
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/sql", bytes.NewReader(body)))
+			if w.Code != code {
+				t.Errorf("%s: expected %d, got %d: %s", sql, code, w.Code, w.Body.String())
+			}
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?path=/History.xlsx.db/sheet1&attach=exp=sub/Expenses.csv.db&filterModel=", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 with attach, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// blobReadAhead is the least BlobCell reads from SQLite at a time.
+const blobReadAhead = 1 << 20
+
+// ErrCellNotFound is returned by OpenBlob for a missing row or a NULL cell.
+var ErrCellNotFound = errors.New("cell not found")
+
+// BlobCell is one cell of a table, read in byte ranges so that large BLOBs are
+// never loaded whole. It implements io.ReaderAt.
+//
+// The SQLite driver does not expose incremental BLOB I/O (sqlite3_blob_open),
+// so ranges are read with substr() and only they are copied out of SQLite.
+type BlobCell struct {
+	Table   string
+	Column  string
+	RowID   int64
+	Storage string // Storage class; text and numbers are read as their UTF-8 text
+	Size    int64
+	Version string // DatabaseVersion when the cell was opened
+
+	ctx   context.Context
+	db    *sql.DB
+	query string
+
+	mu     sync.Mutex
+	buf    []byte // Read-ahead window starting at bufOff
+	bufOff int64
+}
+
+// OpenBlob locates a cell of the table selected by a Banquet path by column and
+// rowid. Reads use ctx.
+func (e *Engine) OpenBlob(ctx context.Context, path, column string, rowid int64) (*BlobCell, error) {
+	pq, err := e.prepareQuery(ctx, QueryOptions{BanquetPath: path})
+	if err != nil {
+		return nil, err
+	}
+	db, table := pq.db, pq.bq.Table
+
+	cols, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if !containsString(cols, column) {
+		return nil, fmt.Errorf("no such column: %s", column)
+	}
+	version, err := e.DatabaseVersion(ctx, pq.dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rowidName := rowIDName(cols)
+	if rowidName == "" {
+		return nil, fmt.Errorf("%s has no rowid", table)
+	}
+	col, from := sqlite.QuoteIdentifier(column), sqlite.QuoteIdentifier(table)
+	cell := &BlobCell{
+		Table:   table,
+		Column:  column,
+		RowID:   rowid,
+		Version: version,
+		ctx:     ctx,
+		db:      db,
+		query:   fmt.Sprintf("SELECT substr(CAST(%s AS BLOB), ?, ?) FROM %s WHERE %s = ?", col, from, rowidName),
+	}
+	var size sql.NullInt64
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT typeof(%s), length(CAST(%s AS BLOB)) FROM %s WHERE %s = ?", col, col, from, rowidName), rowid).
+		Scan(&cell.Storage, &size)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, fmt.Errorf("%w: no row with rowid %d in %s", ErrCellNotFound, rowid, table)
+	case err != nil:
+		if strings.Contains(err.Error(), "no such column: "+rowidName) {
+			return nil, fmt.Errorf("%s has no rowid", table)
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	case cell.Storage == StorageNull:
+		return nil, fmt.Errorf("%w: %s is NULL in row %d", ErrCellNotFound, column, rowid)
+	}
+	cell.Size = size.Int64
+	return cell, nil
+}
+
+// ReadAt reads len(p) bytes of the cell from offset off. Reads go through a
+// read-ahead window, as each one re-reads the cell in SQLite.
+func (c *BlobCell) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+	if off >= c.Size {
+		return 0, io.EOF
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := off + int64(len(p))
+	if end > c.Size {
+		end = c.Size
+	}
+	if off < c.bufOff || end > c.bufOff+int64(len(c.buf)) {
+		want := max(end-off, blobReadAhead)
+		var chunk []byte
+		// substr() counts from 1
+		if err := c.db.QueryRowContext(c.ctx, c.query, off+1, want, c.RowID).Scan(&chunk); err != nil {
+			return 0, fmt.Errorf("error reading %s: %w", c.Column, err)
+		}
+		c.buf, c.bufOff = chunk, off
+	}
+	n := copy(p, c.buf[off-c.bufOff:])
+	if n < len(p) {
+		// The read reached the end of the cell, or the cell shrank
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// rowIDColumn returns the column of a table that aliases its rowid (an INTEGER
+// PRIMARY KEY), or "" if it has none or no rowid at all.
+func rowIDColumn(ctx context.Context, db *sql.DB, table string) string {
+	from := sqlite.QuoteIdentifier(table)
+	// Fails for views and WITHOUT ROWID tables
+	if _, err := db.ExecContext(ctx, "SELECT rowid FROM "+from+" LIMIT 0"); err != nil {
+		return ""
+	}
+	rows, err := db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?) WHERE pk > 0", table)
+	if err != nil {
+		return ""
+	}
+	defer rows.Close()
+	var name, typ string
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(&name, &typ); err != nil {
+			return ""
+		}
+		n++
+	}
+	if n != 1 || !strings.EqualFold(typ, "INTEGER") {
+		return ""
+	}
+	return name
+}
+
+// rowIDName returns the first of SQLite's names for the rowid (rowid, _rowid_
+// and oid) that is not the name of one of a table's columns, or "" if all are.
+func rowIDName(cols []string) string {
+	for _, name := range []string{"rowid", "_rowid_", "oid"} {
+		if !containsFold(cols, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// rowIDQuery appends the rowid to the select list of a composed page query, so
+// that the cells of each row can be addressed at /sqliter/blob. It leaves the
+// query unchanged for views and WITHOUT ROWID tables.
+func rowIDQuery(ctx context.Context, db *sql.DB, table, query string) (string, bool) {
+	cols, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return query, false
+	}
+	name := rowIDName(cols)
+	if name == "" {
+		return query, false
+	}
+	from := sqlite.QuoteIdentifier(table)
+	rowid := from + "." + name
+	// Fails for views and WITHOUT ROWID tables
+	if _, err := db.ExecContext(ctx, "SELECT "+rowid+" FROM "+from+" LIMIT 0"); err != nil {
+		return query, false
+	}
+	// Quoted names cannot contain the FROM clause
+	i := strings.Index(query, " FROM "+from)
+	if i < 0 {
+		return query, false
+	}
+	return query[:i] + ", " + rowid + query[i:], true
+}
+
+// blobURL returns the /sqliter/blob URL of a cell of the table selected by a
+// page query, or "" if the query did not select the rowid.
+func (pq *preparedQuery) blobURL(column string, rowid interface{}) string {
+	id, ok := rowid.(int64)
+	if !pq.withRowID || !ok {
+		return ""
+	}
+	q := url.Values{
+		"path":   {"/" + pq.dbRelPath + "/" + pq.bq.Table},
+		"column": {column},
+		"rowid":  {strconv.FormatInt(id, 10)},
+	}
+	return "/sqliter/blob?" + q.Encode()
+}
@@ -0,0 +1,214 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestBlobDownload(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "media.db")
+	createTestDB(t, dbPath, `
+		CREATE TABLE media (id INTEGER PRIMARY KEY, name TEXT, body BLOB);
+		INSERT INTO media (name, body) VALUES
+			('logo.png', x'89504e470d0a1a0a0000000d49484452'),
+			('big.bin', randomblob(2500000)),
+			('notes', 'caf' || char(233) || ' notes'),
+			('nothing', NULL),
+			('page', '<html><script>alert(1)</script></html>');
+		CREATE TABLE keyed (k TEXT PRIMARY KEY, body BLOB) WITHOUT ROWID;
+	`)
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	var big []byte
+	if err := db.QueryRow("SELECT body FROM media WHERE id = 2").Scan(&big); err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	db.Close()
+
+	srv := NewServer(&Config{ServeFolder: tmpDir, Compression: true})
+	defer srv.engine.CloseAll()
+
+	get := func(url string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+	cell := func(column string, rowid int) string {
+		return fmt.Sprintf("/sqliter/blob?path=/media.db/media&column=%s&rowid=%d", column, rowid)
+	}
+
+	t.Run("Image", func(t *testing.T) {
+		w := get(cell("body", 1))
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || w.Body.Len() != 16 {
+			t.Errorf("Expected a 16 byte PNG, got %d %q %d bytes", w.Code, w.Header().Get("Content-Type"), w.Body.Len())
+		}
+		if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("ETag") == "" {
+			t.Errorf("Missing headers: %v", w.Header())
+		}
+	})
+
+	t.Run("Large", func(t *testing.T) {
+		w := get(cell("body", 2), "Accept-Encoding", "gzip")
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), big) {
+			t.Fatalf("Expected the whole blob, got %d with %d bytes", w.Code, w.Body.Len())
+		}
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected cells to be served uncompressed")
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		// Spans the read-ahead window
+		from, to := blobReadAhead-10, blobReadAhead+9
+		w := get(cell("body", 2), "Range", fmt.Sprintf("bytes=%d-%d", from, to))
+		if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), big[from:to+1]) {
+			t.Fatalf("Expected 20 bytes of partial content, got %d with %d bytes", w.Code, w.Body.Len())
+		}
+		if want := fmt.Sprintf("bytes %d-%d/%d", from, to, len(big)); w.Header().Get("Content-Range") != want {
+			t.Errorf("Expected Content-Range %q, got %q", want, w.Header().Get("Content-Range"))
+		}
+		w = get(cell("body", 2), "Range", "bytes=-5")
+		if !bytes.Equal(w.Body.Bytes(), big[len(big)-5:]) {
+			t.Errorf("Expected the last 5 bytes, got %v", w.Body.Bytes())
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		w := get(cell("name", 3))
+		if w.Body.String() != "notes" || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+			t.Errorf("Unexpected text cell: %q %q", w.Body.String(), w.Header().Get("Content-Type"))
+		}
+		w = get(cell("body", 3), "Range", "bytes=3-4")
+		if w.Body.String() != "é" {
+			t.Errorf("Expected ranges to count bytes, got %q", w.Body.String())
+		}
+		w = get(cell("body", 5))
+		if w.Header().Get("Content-Security-Policy") != "sandbox" {
+			t.Errorf("Expected HTML cells to be sandboxed")
+		}
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		etag := get(cell("body", 1)).Header().Get("ETag")
+		if w := get(cell("body", 1), "If-None-Match", etag); w.Code != http.StatusNotModified {
+			t.Errorf("Expected 304, got %d", w.Code)
+		}
+		createTestDB(t, dbPath, "UPDATE media SET body = x'00' WHERE id = 1")
+		if w := get(cell("body", 1), "If-None-Match", etag); w.Code != http.StatusOK || w.Body.Len() != 1 {
+			t.Errorf("Expected the updated cell, got %d", w.Code)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for url, code := range map[string]int{
+			cell("body", 4):  http.StatusNotFound, // NULL
+			cell("body", 99): http.StatusNotFound,
+			cell("nope", 1):  http.StatusInternalServerError,
+			"/sqliter/blob?path=/media.db/keyed&column=body&rowid=1": http.StatusInternalServerError,
+			"/sqliter/blob?path=/media.db/media&column=body":         http.StatusBadRequest,
+		} {
+			if w := get(url); w.Code != code {
+				t.Errorf("%s: expected %d, got %d: %s", url, code, w.Code, w.Body.String())
+			}
+		}
+	})
+}
+
+func TestRowIDColumn(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "ids.db"), `
+		CREATE TABLE aliased (id INTEGER PRIMARY KEY, body BLOB);
+		CREATE TABLE plain (id INT PRIMARY KEY, body BLOB);
+		CREATE TABLE keyed (id INTEGER PRIMARY KEY, body BLOB) WITHOUT ROWID;
+		CREATE VIEW v AS SELECT * FROM aliased;
+	`)
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	for table, want := range map[string]string{"aliased": "id", "plain": "", "keyed": "", "v": ""} {
+		res, err := engine.Query(ctx, QueryOptions{BanquetPath: "/ids.db/" + table, TypedValues: true})
+		if err != nil {
+			t.Fatalf("Query %s failed: %v", table, err)
+		}
+		if res.RowIDColumn != want {
+			t.Errorf("%s: expected rowid column %q, got %q", table, want, res.RowIDColumn)
+		}
+	}
+	res, _ := engine.Query(ctx, QueryOptions{BanquetPath: "/ids.db/aliased"})
+	if res.RowIDColumn != "" {
+		t.Errorf("Expected no rowid column without TypedValues")
+	}
+}
+
+func TestBlobURLs(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "ids.db"), `
+		CREATE TABLE plain (id INT PRIMARY KEY, body BLOB);
+		INSERT INTO plain VALUES
+			(7, x'89504e470d0a1a0a0000000d49484452'),
+			(8, 'not an image');
+		CREATE TABLE shadowed (rowid TEXT, body BLOB);
+		INSERT INTO shadowed VALUES ('a', x'89504e470d0a1a0a0000000d49484452');
+		CREATE TABLE keyed (id INTEGER PRIMARY KEY, body BLOB) WITHOUT ROWID;
+		INSERT INTO keyed VALUES (1, x'89504e470d0a1a0a0000000d49484452');
+	`)
+	srv := NewServer(&Config{ServeFolder: tmpDir})
+	defer srv.engine.CloseAll()
+	ctx := context.Background()
+
+	for table, want := range map[string]string{
+		"plain":    "/sqliter/blob?column=body&path=%2Fids.db%2Fplain&rowid=1",
+		"shadowed": "/sqliter/blob?column=body&path=%2Fids.db%2Fshadowed&rowid=1",
+		"keyed":    "",
+	} {
+		opts := QueryOptions{BanquetPath: "/ids.db/" + table, TypedValues: true}
+		res, err := srv.engine.Query(ctx, opts)
+		if err != nil {
+			t.Fatalf("Query %s failed: %v", table, err)
+		}
+		if len(res.Columns) != 2 || len(res.Values[0]) != 2 {
+			t.Fatalf("%s: expected the rowid to stay hidden, got %v", table, res.Columns)
+		}
+		if got := res.Values[0][1].(TypedValue).URL; got != want {
+			t.Errorf("%s: expected URL %q, got %q", table, want, got)
+		}
+		if table == "plain" && res.Values[1][1].(TypedValue).URL != "" {
+			t.Errorf("Expected no URL for a text cell")
+		}
+
+		var encoded, streamed bytes.Buffer
+		json.NewEncoder(&encoded).Encode(res)
+		if err := srv.engine.QueryJSON(ctx, opts, &streamed); err != nil {
+			t.Fatalf("QueryJSON %s failed: %v", table, err)
+		}
+		if streamed.String() != encoded.String() {
+			t.Errorf("%s: QueryJSON output differs from Query:\n got %s\nwant %s", table, streamed.String(), encoded.String())
+		}
+	}
+
+	for _, table := range []string{"plain", "shadowed"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/blob?column=body&path=%2Fids.db%2F"+table+"&rowid=1", nil))
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%s: expected the URL to serve the image, got %d %q", table, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
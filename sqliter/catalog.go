@@ -0,0 +1,235 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SavedQuery is a named, reusable view: either a Banquet path with its grid
+// state, or a SQL console statement.
+type SavedQuery struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"` // Unique, e.g. "Q3 overdue invoices"
+	Description string            `json:"description"`
+	BanquetPath string            `json:"banquetPath,omitempty"`
+	DB          string            `json:"db,omitempty"`  // Database of a SQL query
+	SQL         string            `json:"sql,omitempty"` // SQL console statement
+	Attach      map[string]string `json:"attach,omitempty"`
+	ColumnState json.RawMessage   `json:"columnState,omitempty"` // AG Grid column state
+	FilterModel json.RawMessage   `json:"filterModel,omitempty"` // AG Grid filter model
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+// ErrSavedQueryNotFound is returned for an unknown saved query ID or name.
+var ErrSavedQueryNotFound = errors.New("saved query not found")
+
+// Catalog is the sidecar database holding saved queries and the query history. It lives outside the
+// served folder so saving a view never modifies the databases being viewed.
+type Catalog struct {
+	db *sql.DB
+}
+
+const catalogSchema = `
+CREATE TABLE IF NOT EXISTS saved_queries (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	description TEXT NOT NULL DEFAULT '',
+	banquet_path TEXT NOT NULL DEFAULT '',
+	db TEXT NOT NULL DEFAULT '',
+	sql TEXT NOT NULL DEFAULT '',
+	attach TEXT,
+	column_state TEXT,
+	filter_model TEXT,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);`
+
+// OpenCatalog opens or creates the catalog database at path.
+func OpenCatalog(path string) (*Catalog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating catalog dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(catalogSchema + historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating catalog: %w", err)
+	}
+	return &Catalog{db: db}, nil
+}
+
+// Close closes the catalog database.
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// catalogPath returns Config.CatalogPath, or .sqliter/catalog.db under LogDir.
+func (cfg *Config) catalogPath() string {
+	if cfg.CatalogPath != "" {
+		return cfg.CatalogPath
+	}
+	logDir := cfg.LogDir
+	if logDir == "" {
+		logDir = "logs"
+	}
+	return filepath.Join(logDir, ".sqliter", "catalog.db")
+}
+
+// Catalog returns the engine's catalog, opening it on first use.
+func (e *Engine) Catalog() (*Catalog, error) {
+	e.catalogMu.Lock()
+	defer e.catalogMu.Unlock()
+	if e.catalog == nil {
+		c, err := OpenCatalog(e.config.catalogPath())
+		if err != nil {
+			return nil, err
+		}
+		e.catalog = c
+	}
+	return e.catalog, nil
+}
+
+// nullJSON stores empty values as NULL.
+func nullJSON(v []byte) interface{} {
+	if len(v) == 0 || string(v) == "null" {
+		return nil
+	}
+	return string(v)
+}
+
+const savedQueryColumns = "id, name, description, banquet_path, db, sql, attach, column_state, filter_model, created_at, updated_at"
+
+func scanSavedQuery(scan func(...interface{}) error) (*SavedQuery, error) {
+	var q SavedQuery
+	var attach, columnState, filterModel sql.NullString
+	var created, updated string
+	if err := scan(&q.ID, &q.Name, &q.Description, &q.BanquetPath, &q.DB, &q.SQL, &attach, &columnState, &filterModel, &created, &updated); err != nil {
+		return nil, err
+	}
+	if attach.Valid {
+		json.Unmarshal([]byte(attach.String), &q.Attach)
+	}
+	if columnState.Valid {
+		q.ColumnState = json.RawMessage(columnState.String)
+	}
+	if filterModel.Valid {
+		q.FilterModel = json.RawMessage(filterModel.String)
+	}
+	q.CreatedAt, _ = time.Parse(time.RFC3339Nano, created)
+	q.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updated)
+	return &q, nil
+}
+
+// List returns the saved queries, by name, whose name or description contains search.
+func (c *Catalog) List(ctx context.Context, search string) ([]SavedQuery, error) {
+	query := "SELECT " + savedQueryColumns + " FROM saved_queries"
+	var args []interface{}
+	if search != "" {
+		query += ` WHERE name LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\'`
+		pattern := "%" + escapeLike(search) + "%"
+		args = append(args, pattern, pattern)
+	}
+	query += " ORDER BY name"
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("catalog error: %w", err)
+	}
+	defer rows.Close()
+
+	queries := make([]SavedQuery, 0)
+	for rows.Next() {
+		q, err := scanSavedQuery(rows.Scan)
+		if err != nil {
+			continue
+		}
+		queries = append(queries, *q)
+	}
+	return queries, rows.Err()
+}
+
+// Get returns a saved query by ID, or by name (case-insensitively) if id is 0.
+func (c *Catalog) Get(ctx context.Context, id int64, name string) (*SavedQuery, error) {
+	row := c.db.QueryRowContext(ctx, "SELECT "+savedQueryColumns+" FROM saved_queries WHERE id = ? OR (? = 0 AND name = ?)", id, id, name)
+	q, err := scanSavedQuery(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrSavedQueryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("catalog error: %w", err)
+	}
+	return q, nil
+}
+
+// Save creates the query if its ID is 0 and updates it otherwise, returning the stored query.
+func (c *Catalog) Save(ctx context.Context, q SavedQuery) (*SavedQuery, error) {
+	q.Name = strings.TrimSpace(q.Name)
+	if q.Name == "" {
+		return nil, fmt.Errorf("name required")
+	}
+	if (q.BanquetPath == "") == (q.SQL == "") {
+		return nil, fmt.Errorf("exactly one of banquetPath and sql required")
+	}
+	if q.SQL != "" && q.DB == "" {
+		return nil, fmt.Errorf("db required for sql queries")
+	}
+
+	var attach []byte
+	if len(q.Attach) > 0 {
+		attach, _ = json.Marshal(q.Attach)
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	var err error
+	if q.ID == 0 {
+		var res sql.Result
+		res, err = c.db.ExecContext(ctx, `INSERT INTO saved_queries
+			(name, description, banquet_path, db, sql, attach, column_state, filter_model, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			q.Name, q.Description, q.BanquetPath, q.DB, q.SQL, nullJSON(attach), nullJSON(q.ColumnState), nullJSON(q.FilterModel), now, now)
+		if err == nil {
+			q.ID, err = res.LastInsertId()
+		}
+	} else {
+		var res sql.Result
+		res, err = c.db.ExecContext(ctx, `UPDATE saved_queries SET
+			name = ?, description = ?, banquet_path = ?, db = ?, sql = ?, attach = ?, column_state = ?, filter_model = ?, updated_at = ?
+			WHERE id = ?`,
+			q.Name, q.Description, q.BanquetPath, q.DB, q.SQL, nullJSON(attach), nullJSON(q.ColumnState), nullJSON(q.FilterModel), now, q.ID)
+		if err == nil {
+			if n, _ := res.RowsAffected(); n == 0 {
+				return nil, ErrSavedQueryNotFound
+			}
+		}
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("a saved query named %q already exists", q.Name)
+		}
+		return nil, fmt.Errorf("catalog error: %w", err)
+	}
+	return c.Get(ctx, q.ID, "")
+}
+
+// Delete removes a saved query.
+func (c *Catalog) Delete(ctx context.Context, id int64) error {
+	res, err := c.db.ExecContext(ctx, "DELETE FROM saved_queries WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("catalog error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSavedQueryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestCatalog(t *testing.T) {
+	logDir := t.TempDir()
+	engine := NewEngine(&Config{ServeFolder: t.TempDir(), LogDir: logDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	catalog, err := engine.Catalog()
+	if err != nil {
+		t.Fatalf("Catalog failed: %v", err)
+	}
+
+	saved, err := catalog.Save(ctx, SavedQuery{
+		Name:        "Q3 overdue invoices",
+		Description: "Unpaid after 30 days",
+		BanquetPath: "/billing.db/invoices?where=paid=0",
+		FilterModel: json.RawMessage(`{"due":{"filterType":"date","type":"lessThan","dateFrom":"2024-09-30"}}`),
+	})
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if saved.ID == 0 || saved.CreatedAt.IsZero() || string(saved.FilterModel) == "" {
+		t.Fatalf("Unexpected saved query: %+v", saved)
+	}
+
+	if _, err := catalog.Save(ctx, SavedQuery{Name: "q3 OVERDUE invoices", SQL: "SELECT 1", DB: "billing.db"}); err == nil {
+		t.Error("Expected names to be unique case-insensitively")
+	}
+	if _, err := catalog.Save(ctx, SavedQuery{Name: "both", SQL: "SELECT 1", BanquetPath: "/x.db/t"}); err == nil {
+		t.Error("Expected an error for a query with both a path and SQL")
+	}
+
+	byName, err := catalog.Get(ctx, 0, "q3 overdue invoices")
+	if err != nil || byName.ID != saved.ID {
+		t.Fatalf("Expected lookup by name, got %+v (%v)", byName, err)
+	}
+
+	saved.Description = "Unpaid after 45 days"
+	if _, err := catalog.Save(ctx, *saved); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	list, err := catalog.List(ctx, "45 days")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Expected the updated query in search results, got %+v (%v)", list, err)
+	}
+
+	if err := catalog.Delete(ctx, saved.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := catalog.Get(ctx, saved.ID, ""); !errors.Is(err, ErrSavedQueryNotFound) {
+		t.Errorf("Expected ErrSavedQueryNotFound, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(logDir, ".sqliter", "catalog.db")); err != nil {
+		t.Errorf("Expected the catalog under LogDir: %v", err)
+	}
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: t.TempDir(), CatalogPath: filepath.Join(t.TempDir(), "catalog.db")})
+		body, _ := json.Marshal(SavedQuery{Name: "Big spenders", DB: "shop.db", SQL: "SELECT * FROM orders WHERE total > 100"})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/catalog", bytes.NewReader(body)))
+		var created SavedQuery
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&created) != nil || created.ID == 0 {
+			t.Fatalf("Create failed with %d: %s", w.Code, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/catalog?name=big%20spenders", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("DELETE", "/sqliter/catalog?id=999", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+}
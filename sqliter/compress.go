@@ -0,0 +1,289 @@
+package sqliter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultCompressionMinSize is the smallest response body compressed by default.
+const DefaultCompressionMinSize = 1024
+
+// compressionEncodings lists the supported content codings, most preferred first.
+var compressionEncodings = []string{"br", "zstd", "gzip"}
+
+// compressor is a content coding writer that can be reused for another response.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressorPools reuses encoders per coding for one compression level.
+type compressorPools struct {
+	level int
+	pools map[string]*sync.Pool
+}
+
+func newCompressorPools(level int) *compressorPools {
+	p := &compressorPools{level: level, pools: make(map[string]*sync.Pool)}
+	for _, enc := range compressionEncodings {
+		enc := enc
+		p.pools[enc] = &sync.Pool{New: func() interface{} { return p.newCompressor(enc) }}
+	}
+	return p
+}
+
+// newCompressor creates an encoder. Levels are those of each coding (gzip 1-9,
+// zstd 1-22, brotli 0-11) and are clamped to its range; 0 means its default.
+func (p *compressorPools) newCompressor(enc string) compressor {
+	level := p.level
+	switch enc {
+	case "br":
+		if level == 0 {
+			level = 5 // brotli.DefaultCompression is too slow for dynamic responses
+		}
+		return brotli.NewWriterLevel(nil, clamp(level, brotli.BestSpeed, brotli.BestCompression))
+	case "zstd":
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(clamp(level, 1, 22))))
+		}
+		w, _ := zstd.NewWriter(nil, opts...) // Only fails on invalid options
+		return w
+	default:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else {
+			level = clamp(level, gzip.BestSpeed, gzip.BestCompression)
+		}
+		w, _ := gzip.NewWriterLevel(nil, level) // Level is valid
+		return w
+	}
+}
+
+func (p *compressorPools) get(enc string, w io.Writer) compressor {
+	c := p.pools[enc].Get().(compressor)
+	c.Reset(w)
+	return c
+}
+
+func (p *compressorPools) put(enc string, c compressor) {
+	p.pools[enc].Put(c)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// negotiateEncoding picks the preferred supported coding the Accept-Encoding
+// header allows, or "" for an uncompressed response.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	for _, enc := range compressionEncodings {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			return enc
+		}
+	}
+	return ""
+}
+
+// compressible reports whether a content type benefits from compression.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/javascript",
+		"application/xml", "application/wasm", "image/svg+xml",
+		"application/vnd.apache.arrow.stream", "application/msgpack":
+		return true
+	}
+	return false
+}
+
+// compressWriter compresses a response once its body reaches minSize, or when
+// it is flushed, if its status and content type allow it. Smaller bodies are
+// written as they are when the handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	pools    *compressorPools
+	encoding string
+	minSize  int
+
+	status  int
+	buf     bytes.Buffer
+	decided bool       // Whether the headers were written
+	c       compressor // Nil when the response is not compressed
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if status < 200 {
+		cw.ResponseWriter.WriteHeader(status) // Informational, e.g. 103 Early Hints
+		return
+	}
+	if cw.status == 0 {
+		cw.status = status
+	}
+	// Bodiless responses are never compressed
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf.Write(p)
+		if cw.buf.Len() >= cw.minSize {
+			if err := cw.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if cw.c != nil {
+		return cw.c.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// start writes the headers, compressed if wanted and allowed, then the buffered body.
+func (cw *compressWriter) start(compress bool) error {
+	if cw.decided {
+		return nil
+	}
+	cw.decided = true
+	h := cw.Header()
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if compress && cw.status == http.StatusOK && h.Get("Content-Encoding") == "" {
+		contentType := h.Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(cw.buf.Bytes())
+		}
+		if compressible(contentType) {
+			h.Set("Content-Encoding", cw.encoding)
+			h.Del("Content-Length")
+			cw.c = cw.pools.get(cw.encoding, cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.c != nil {
+		_, err = cw.c.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+// Flush sends everything written so far, compressed, to the client, so
+// streaming responses arrive chunk by chunk.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.buf.Len() == 0 && cw.status == 0 {
+			return // Nothing to send yet; keep the choice open
+		}
+		cw.start(true)
+	}
+	if cw.c != nil {
+		cw.c.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// close finishes the response: small bodies are sent uncompressed and the encoder is returned to its pool.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && cw.buf.Len() == 0 {
+			return // The handler wrote nothing; let net/http send its default response
+		}
+		cw.start(false)
+	}
+	if cw.c != nil {
+		cw.c.Close()
+		cw.pools.put(cw.encoding, cw.c)
+		cw.c = nil
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressionPools returns the server's encoder pools, created on first use.
+func (s *Server) compressionPools() *compressorPools {
+	s.compressOnce.Do(func() {
+		s.compressors = newCompressorPools(s.config.CompressionLevel)
+	})
+	return s.compressors
+}
+
+// withCompression wraps a response in a compressWriter when compression is on and
+// the client accepts a supported coding. The returned function must be called
+// once the handler is done.
+func (s *Server) withCompression(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	if !s.config.Compression || r.Method == http.MethodHead {
+		return w, func() {}
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	// Byte ranges address the uncompressed representation, and cells are
+	// served byte for byte under strong ETags
+	if r.Header.Get("Range") != "" || strings.HasPrefix(r.URL.Path, "/sqliter/blob") {
+		return w, func() {}
+	}
+	enc := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if enc == "" {
+		return w, func() {}
+	}
+	minSize := s.config.CompressionMinSize
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	cw := &compressWriter{ResponseWriter: w, pools: s.compressionPools(), encoding: enc, minSize: minSize}
+	return cw, cw.close
+}
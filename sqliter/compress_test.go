@@ -0,0 +1,191 @@
+package sqliter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	_ "modernc.org/sqlite"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                           "",
+		"identity":                   "",
+		"gzip":                       "gzip",
+		"gzip, deflate, br, zstd":    "br",
+		"gzip;q=0.5, zstd":           "zstd",
+		"br;q=0, gzip":               "gzip",
+		"*":                          "br",
+		"*;q=0, gzip":                "gzip",
+		"deflate, GZIP;q=0.1":        "gzip",
+		"br;q=0, zstd;q=0, gzip;q=0": "",
+	} {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func decompress(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		r = gr
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return body
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", encoding, err)
+	}
+	return out
+}
+
+func TestCompression(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "wide.db"), `
+		CREATE TABLE docs (id INTEGER PRIMARY KEY, body TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 200)
+		INSERT INTO docs (body) SELECT 'lorem ipsum dolor sit amet ' || i FROM n;
+	`)
+	createTestDB(t, filepath.Join(tmpDir, "wide2.db"), `
+		CREATE TABLE docs (id INTEGER PRIMARY KEY, body TEXT);
+	`)
+
+	srv := NewServer(&Config{ServeFolder: tmpDir, Compression: true, CompressionMinSize: 256})
+	defer srv.engine.CloseAll()
+
+	get := func(url, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, enc := range []string{"gzip", "zstd", "br"} {
+		t.Run(enc, func(t *testing.T) {
+			w := get("/sqliter/rows?path=/wide.db/docs&start=0&end=200", enc)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Encoding"); got != enc {
+				t.Fatalf("Expected Content-Encoding %s, got %q", enc, got)
+			}
+			if !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+				t.Errorf("Expected Vary: Accept-Encoding")
+			}
+			var res QueryResult
+			if err := json.Unmarshal(decompress(t, enc, w.Body.Bytes()), &res); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			if len(res.Values) != 200 {
+				t.Errorf("Expected 200 rows, got %d", len(res.Values))
+			}
+		})
+	}
+
+	t.Run("Identity", func(t *testing.T) {
+		w := get("/sqliter/rows?path=/wide.db/docs", "")
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected no compression without Accept-Encoding")
+		}
+		if !json.Valid(w.Body.Bytes()) {
+			t.Errorf("Expected a plain JSON body")
+		}
+	})
+
+	t.Run("SmallBody", func(t *testing.T) {
+		w := get("/sqliter/tables?db=wide2.db", "gzip")
+		if w.Header().Get("Content-Encoding") != "" || !json.Valid(w.Body.Bytes()) {
+			t.Errorf("Expected a small body to stay uncompressed, got %q", w.Header().Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		w := get("/sqliter/rows?path=/wide.db/docs", "gzip")
+		req := httptest.NewRequest("GET", "/sqliter/rows?path=/wide.db/docs", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		w = httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusNotModified || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+			t.Errorf("Expected a bare 304, got %d %q", w.Code, w.Header().Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		w := get("/sqliter/diff/data?a=/wide.db/docs&b=/wide2.db/docs", "zstd")
+		if w.Header().Get("Content-Encoding") != "zstd" {
+			t.Fatalf("Expected a zstd stream, got %q", w.Header().Get("Content-Encoding"))
+		}
+		lines := strings.Split(strings.TrimSpace(string(decompress(t, "zstd", w.Body.Bytes()))), "\n")
+		if len(lines) != 200 || !json.Valid([]byte(lines[199])) {
+			t.Errorf("Expected 200 JSON lines, got %d", len(lines))
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		srv := NewServer(&Config{ServeFolder: tmpDir})
+		defer srv.engine.CloseAll()
+		req := httptest.NewRequest("GET", "/sqliter/rows?path=/wide.db/docs", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected no compression when disabled")
+		}
+	})
+}
+
+func TestCompressWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	cw := &compressWriter{ResponseWriter: rec, pools: newCompressorPools(0), encoding: "gzip", minSize: 1 << 20}
+	cw.Header().Set("Content-Type", "application/x-ndjson")
+	cw.Write([]byte(`{"chunk":1}` + "\n"))
+	cw.Flush()
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a flushed gzip response, got flushed=%v %q", rec.Flushed, rec.Header().Get("Content-Encoding"))
+	}
+	// A flushed chunk is decodable before the stream ends
+	gr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	chunk := make([]byte, 64)
+	n, _ := gr.Read(chunk)
+	if string(chunk[:n]) != `{"chunk":1}`+"\n" {
+		t.Errorf("Expected the first chunk, got %q", chunk[:n])
+	}
+
+	cw.Write([]byte(`{"chunk":2}` + "\n"))
+	cw.close()
+	if got := string(decompress(t, "gzip", rec.Body.Bytes())); got != `{"chunk":1}`+"\n"+`{"chunk":2}`+"\n" {
+		t.Errorf("Unexpected stream: %q", got)
+	}
+}
@@ -2,6 +2,9 @@ package sqliter
 
 const MaxRowsBuffer = 200
 
+// DefaultResultCacheBytes is the default size of the query result cache.
+const DefaultResultCacheBytes = 64 << 20
+
 // Config holds the configuration for the sqliter server.
 type Config struct {
 	// AutoRedirectSingleTable enables or disables automatic redirection when a database has only one table.
@@ -16,6 +19,32 @@ type Config struct {
 
 	LogDir string `hcl:"log_dir,optional"`
 
+	// CatalogPath is the sidecar database holding saved queries and the query history.
+	// Defaults to .sqliter/catalog.db under LogDir.
+	CatalogPath string `hcl:"catalog_path,optional"`
+
+	// QueryHistory records every executed query in the catalog.
+	QueryHistory bool `hcl:"query_history,optional"`
+
+	// ResultCacheBytes bounds the in-memory cache of query results; 0 disables it.
+	ResultCacheBytes int64 `hcl:"result_cache_bytes,optional"`
+
+	// Compression compresses API and UI responses with brotli, zstd or gzip,
+	// as negotiated with the client.
+	Compression bool `hcl:"compression,optional"`
+
+	// CompressionMinSize is the smallest body compressed, in bytes.
+	// Defaults to DefaultCompressionMinSize.
+	CompressionMinSize int `hcl:"compression_min_size,optional"`
+
+	// CompressionLevel is passed to the negotiated encoder (gzip 1-9, zstd 1-22,
+	// brotli 0-11); 0 uses a default suited to dynamic responses.
+	CompressionLevel int `hcl:"compression_level,optional"`
+
+	// Writable allows API calls that modify served databases (e.g. creating FTS indexes).
+	// Defaults to false so the HTTP server stays read-only.
+	Writable bool `hcl:"writable,optional"`
+
 	// BaseURL is the prefix where the app is mounted (e.g. "/tools/sqliter").
 	// This is used to inject configuration into the React client.
 	BaseURL string `hcl:"base_url,optional"`
@@ -28,6 +57,11 @@ func DefaultConfig() *Config {
 		ServeFolder:             "sample_data",
 		Verbose:                 false,
 		LogDir:                  "logs",
+		QueryHistory:            true,
+		ResultCacheBytes:        DefaultResultCacheBytes,
+		Compression:             true,
+		CompressionMinSize:      DefaultCompressionMinSize,
+		Writable:                false,
 		BaseURL:                 "",
 	}
 }
@@ -0,0 +1,202 @@
+package sqliter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultSQLRowLimit caps the rows ExecuteSQL returns when SQLOptions.Limit is not set.
+const DefaultSQLRowLimit = 1000
+
+// SQLOptions is a statement typed into the SQL console.
+type SQLOptions struct {
+	DB     string            `json:"db"`               // Database path relative to ServeFolder
+	SQL    string            `json:"sql"`              // A single statement
+	Attach map[string]string `json:"attach,omitempty"` // Alias -> database path, queryable as alias.table
+	Limit  int               `json:"limit,omitempty"`  // Rows to return; <= 0 uses DefaultSQLRowLimit
+}
+
+// ErrRejected wraps the errors of statements and options that are refused
+// before reaching the database, as opposed to errors the database reports.
+var ErrRejected = errors.New("rejected")
+
+// readOnlyPrefixes are the statements the console runs when Config.Writable is off.
+var readOnlyPrefixes = []string{"SELECT", "WITH", "VALUES", "EXPLAIN"}
+
+// sandboxEscapes would reach files outside ServeFolder or bypass read-only mode,
+// so they are refused even when writable.
+var sandboxEscapes = []string{"ATTACH", "DETACH", "VACUUM", "PRAGMA"}
+
+// firstKeyword returns the upper-cased leading keyword of a statement, skipping comments.
+func firstKeyword(stmt string) string {
+	s := strings.TrimSpace(stmt)
+	for {
+		switch {
+		case strings.HasPrefix(s, "--"):
+			if i := strings.Index(s, "\n"); i >= 0 {
+				s = strings.TrimSpace(s[i+1:])
+				continue
+			}
+			return ""
+		case strings.HasPrefix(s, "/*"):
+			if i := strings.Index(s, "*/"); i >= 0 {
+				s = strings.TrimSpace(s[i+2:])
+				continue
+			}
+			return ""
+		}
+		break
+	}
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z')
+	})
+	if end == -1 {
+		end = len(s)
+	}
+	return strings.ToUpper(s[:end])
+}
+
+// singleStatement reports whether sql holds at most one statement, ignoring
+// semicolons inside quotes and comments and trailing ones.
+func singleStatement(sql string) bool {
+	ended := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			j := strings.IndexByte(sql[i+1:], closer)
+			if j == -1 {
+				return !ended
+			}
+			if ended {
+				return false
+			}
+			i += j + 1
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			j := strings.IndexByte(sql[i:], '\n')
+			if j == -1 {
+				return true
+			}
+			i += j
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			j := strings.Index(sql[i+2:], "*/")
+			if j == -1 {
+				return true
+			}
+			i += j + 3
+		case c == ';':
+			ended = true
+		case ended && c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			return false
+		}
+	}
+	return true
+}
+
+// ExecuteSQL runs one statement from the SQL console against a database, with
+// optional attached databases for cross-file joins. Unless Config.Writable is
+// set only queries are allowed, and the connection is put in query_only mode
+// for the duration of the statement.
+func (e *Engine) ExecuteSQL(ctx context.Context, opts SQLOptions) (*QueryResult, error) {
+	start := time.Now()
+	res, err := e.executeSQL(ctx, opts)
+	h := HistoryEntry{Kind: "sql", DB: strings.TrimPrefix(opts.DB, "/"), SQL: opts.SQL}
+	if res != nil {
+		h.RowCount = int64(len(res.Values))
+	}
+	e.recordHistory(h, opts, start, err)
+	return res, err
+}
+
+func (e *Engine) executeSQL(ctx context.Context, opts SQLOptions) (*QueryResult, error) {
+	start := time.Now()
+	if strings.TrimSpace(opts.SQL) == "" {
+		return nil, fmt.Errorf("%w: sql required", ErrRejected)
+	}
+	if !singleStatement(opts.SQL) {
+		return nil, fmt.Errorf("%w: only one statement can be run at a time", ErrRejected)
+	}
+	keyword := firstKeyword(opts.SQL)
+	if containsString(sandboxEscapes, keyword) {
+		return nil, fmt.Errorf("%w: %s statements are not allowed, use the attach option instead", ErrRejected, keyword)
+	}
+	if !e.config.Writable && !containsString(readOnlyPrefixes, keyword) {
+		return nil, fmt.Errorf("%w: server is read-only, only queries are allowed", ErrRejected)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultSQLRowLimit
+	}
+
+	db, err := e.openDB(ctx, opts.DB)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Attach) > 0 {
+		path, err := e.resolveDBPath(strings.TrimPrefix(opts.DB, "/"))
+		if err != nil {
+			return nil, err
+		}
+		if db, err = e.openAttached(ctx, path, opts.Attach); err != nil {
+			return nil, err
+		}
+		defer db.Close()
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+	defer conn.Close()
+	if !e.config.Writable {
+		if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+	}
+
+	rows, err := conn.QueryContext(ctx, opts.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	result := &QueryResult{SQL: opts.SQL, Values: make([][]interface{}, 0)}
+	result.Columns, err = rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("error getting columns: %w", err)
+	}
+	result.ColumnTypes = resultColumnTypes(rows)
+	values := make([]interface{}, len(result.Columns))
+	valuePtrs := make([]interface{}, len(result.Columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result.Values) == opts.Limit {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("query error: %w", err)
+		}
+		rowData := make([]interface{}, len(values))
+		for i, v := range values {
+			rowData[i] = normalizeValue(v)
+		}
+		result.Values = append(result.Values, rowData)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	result.TotalCount = len(result.Values)
+
+	fmt.Printf("[Engine.ExecuteSQL] %d rows in %v\n", result.TotalCount, time.Since(start))
+	return result, nil
+}
@@ -0,0 +1,169 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// Count strategies for QueryOptions.CountStrategy.
+const (
+	CountExact     = "exact"     // Run COUNT(*) on every request (default)
+	CountCached    = "cached"    // Reuse a COUNT(*) until the database changes
+	CountEstimated = "estimated" // Use sqlite_stat1 or max(rowid) for unfiltered tables, cached counts otherwise
+)
+
+// Count types reported in QueryResult.CountType.
+const (
+	CountTypeExact     = "exact"
+	CountTypeEstimated = "estimated"
+)
+
+// maxCachedCounts bounds the count cache; it is emptied when full.
+const maxCachedCounts = 1000
+
+type cachedCount struct {
+	version string
+	count   int
+}
+
+// countCache holds COUNT(*) results keyed by database and count query, which
+// embeds the table and WHERE clause.
+type countCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedCount
+}
+
+func newCountCache() *countCache {
+	return &countCache{entries: make(map[string]cachedCount)}
+}
+
+func (c *countCache) get(key, version string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.version != version {
+		return 0, false
+	}
+	return entry.count, true
+}
+
+func (c *countCache) put(key, version string, count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedCounts {
+		c.entries = make(map[string]cachedCount)
+	}
+	c.entries[key] = cachedCount{version: version, count: count}
+}
+
+func (c *countCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedCount)
+}
+
+// dataVersion identifies the state of a database as seen by its connection.
+// PRAGMA data_version only changes on commits by other connections, so the
+// connection's own total_changes() and the schema version are included to catch
+// writes and schema changes made through it.
+func dataVersion(ctx context.Context, db *sql.DB) (string, error) {
+	var version, schema, changes int64
+	err := db.QueryRowContext(ctx, `SELECT (SELECT data_version FROM pragma_data_version),
+		(SELECT schema_version FROM pragma_schema_version), total_changes()`).Scan(&version, &schema, &changes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%d:%d", version, schema, changes), nil
+}
+
+// totalCount returns the total row count for a prepared query according to
+// opts.CountStrategy, with its count type, or -1 when the count is skipped or fails.
+func (e *Engine) totalCount(ctx context.Context, pq *preparedQuery, opts QueryOptions) (int, string) {
+	if opts.SkipTotalCount {
+		return -1, ""
+	}
+
+	strategy := opts.CountStrategy
+	if strategy == CountEstimated {
+		if pq.bq.Where == "" && len(opts.GroupBy) == 0 {
+			if n, ok := estimateTableRows(ctx, pq.db, pq.bq.Table); ok {
+				return int(n), CountTypeEstimated
+			}
+		}
+		// Filtered and grouped counts cannot be estimated from table statistics
+		strategy = CountCached
+	}
+
+	// Attached databases have their own data versions, so their counts are not cached
+	if strategy != CountCached || len(opts.Attach) > 0 {
+		count := -1
+		if err := pq.db.QueryRowContext(ctx, pq.countQuery).Scan(&count); err != nil {
+			return -1, ""
+		}
+		return count, CountTypeExact
+	}
+
+	version, err := dataVersion(ctx, pq.db)
+	if err != nil {
+		return -1, ""
+	}
+	key := pq.dbRelPath + "\x00" + pq.countQuery
+	if count, ok := e.counts.get(key, version); ok {
+		return count, CountTypeExact
+	}
+	count := -1
+	if err := pq.db.QueryRowContext(ctx, pq.countQuery).Scan(&count); err != nil {
+		return -1, ""
+	}
+	e.counts.put(key, version, count)
+	return count, CountTypeExact
+}
+
+// estimateTableRows estimates a table's row count from sqlite_stat1, written by
+// ANALYZE, or from max(rowid), which overcounts tables with deleted rows.
+func estimateTableRows(ctx context.Context, db *sql.DB, table string) (int64, bool) {
+	if n, ok := statRowCount(ctx, db, table); ok {
+		return n, true
+	}
+	var n sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(rowid) FROM "+sqlite.QuoteIdentifier(table)).Scan(&n); err != nil {
+		return 0, false // Views and WITHOUT ROWID tables
+	}
+	return n.Int64, true
+}
+
+// statRowCount reads the largest row count sqlite_stat1 records for a table. The
+// first number of each stat is the row count of the table or of one of its indexes.
+func statRowCount(ctx context.Context, db *sql.DB, table string) (int64, bool) {
+	rows, err := db.QueryContext(ctx, "SELECT stat FROM sqlite_stat1 WHERE tbl = ?", table)
+	if err != nil {
+		return 0, false // No ANALYZE yet
+	}
+	defer rows.Close()
+
+	var count int64
+	found := false
+	for rows.Next() {
+		var stat string
+		if err := rows.Scan(&stat); err != nil {
+			continue
+		}
+		fields := strings.Fields(stat)
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			if !found || n > count {
+				count = n
+			}
+			found = true
+		}
+	}
+	return count, found && rows.Err() == nil
+}
@@ -0,0 +1,115 @@
+package sqliter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestCountStrategies(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "big.db")
+	createTestDB(t, dbPath, `
+		CREATE TABLE events (id INTEGER PRIMARY KEY, kind TEXT);
+		CREATE INDEX events_kind ON events(kind);
+		INSERT INTO events (kind) VALUES ('a'), ('b'), ('a'), ('c');
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir, Writable: true})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	count := func(t *testing.T, strategy, where string) (int, string) {
+		t.Helper()
+		res, err := engine.Query(ctx, QueryOptions{BanquetPath: "/big.db/events", FilterWhere: where, CountStrategy: strategy})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		return res.TotalCount, res.CountType
+	}
+
+	t.Run("Exact", func(t *testing.T) {
+		if n, typ := count(t, "", ""); n != 4 || typ != CountTypeExact {
+			t.Errorf("Expected exact count 4, got %d (%s)", n, typ)
+		}
+		res, err := engine.Query(ctx, QueryOptions{BanquetPath: "/big.db/events", SkipTotalCount: true})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if res.TotalCount != -1 || res.CountType != "" {
+			t.Errorf("Expected no count when skipped, got %d (%s)", res.TotalCount, res.CountType)
+		}
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		if n, typ := count(t, CountCached, "kind = 'a'"); n != 2 || typ != CountTypeExact {
+			t.Errorf("Expected cached count 2, got %d (%s)", n, typ)
+		}
+		if len(engine.counts.entries) != 1 {
+			t.Fatalf("Expected 1 cached count, got %d", len(engine.counts.entries))
+		}
+
+		// A commit by another connection changes data_version
+		createTestDB(t, dbPath, "INSERT INTO events (kind) VALUES ('a')")
+		if n, _ := count(t, CountCached, "kind = 'a'"); n != 3 {
+			t.Errorf("Expected the count to follow an external write, got %d", n)
+		}
+
+		// A write through the engine's own connection leaves data_version unchanged
+		if _, err := engine.ExecuteSQL(ctx, SQLOptions{DB: "big.db", SQL: "DELETE FROM events WHERE id = 1"}); err != nil {
+			t.Fatalf("ExecuteSQL failed: %v", err)
+		}
+		if n, _ := count(t, CountCached, "kind = 'a'"); n != 2 {
+			t.Errorf("Expected the count to follow an own write, got %d", n)
+		}
+	})
+
+	t.Run("Estimated", func(t *testing.T) {
+		// max(rowid) counts the deleted row 1
+		if n, typ := count(t, CountEstimated, ""); n != 5 || typ != CountTypeEstimated {
+			t.Errorf("Expected max(rowid) estimate 5, got %d (%s)", n, typ)
+		}
+
+		createTestDB(t, dbPath, "ANALYZE; INSERT INTO events (kind) VALUES ('d')")
+		if n, typ := count(t, CountEstimated, ""); n != 4 || typ != CountTypeEstimated {
+			t.Errorf("Expected sqlite_stat1 estimate 4, got %d (%s)", n, typ)
+		}
+
+		if n, typ := count(t, CountEstimated, "kind = 'a'"); n != 2 || typ != CountTypeExact {
+			t.Errorf("Expected an exact count for a filtered query, got %d (%s)", n, typ)
+		}
+	})
+}
+
+func TestStatRowCount(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "t.db"), `
+		CREATE TABLE kv (k TEXT PRIMARY KEY, v TEXT) WITHOUT ROWID;
+		INSERT INTO kv VALUES ('a', '1'), ('b', '2');
+		CREATE VIEW kv_view AS SELECT * FROM kv;
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+	db, err := engine.openDB(ctx, "t.db")
+	if err != nil {
+		t.Fatalf("openDB failed: %v", err)
+	}
+
+	if _, ok := estimateTableRows(ctx, db, "kv"); ok {
+		t.Error("Expected no estimate for a WITHOUT ROWID table before ANALYZE")
+	}
+	if _, ok := estimateTableRows(ctx, db, "kv_view"); ok {
+		t.Error("Expected no estimate for a view")
+	}
+
+	if _, err := db.Exec("ANALYZE"); err != nil {
+		t.Fatalf("ANALYZE failed: %v", err)
+	}
+	if n, ok := estimateTableRows(ctx, db, "kv"); !ok || n != 2 {
+		t.Errorf("Expected estimate 2 from sqlite_stat1, got %d, %v", n, ok)
+	}
+}
@@ -0,0 +1,416 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// diffSchemaName is the name B is attached under while diffing.
+const diffSchemaName = "sqliter_b"
+
+// DataDiffOptions selects the two tables to compare.
+type DataDiffOptions struct {
+	A   string   // Banquet path of the old table, e.g. /v1.xlsx.db/sheet1
+	B   string   // Banquet path of the new table; may be in the same or another database
+	Key []string // Columns identifying a row; defaults to A's primary key (or rowid)
+}
+
+// ColumnChange is the old and new value of one column of a changed row.
+type ColumnChange struct {
+	Column string      `json:"column"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// RowDiff is one added, removed or changed row.
+type RowDiff struct {
+	Change  string                 `json:"change"` // added, removed or changed
+	Key     map[string]interface{} `json:"key"`
+	Row     map[string]interface{} `json:"row,omitempty"`     // Full row for added and removed rows
+	Columns []ColumnChange         `json:"columns,omitempty"` // Differing columns of changed rows
+}
+
+// DataDiffSummary counts the differences found by DiffData.
+type DataDiffSummary struct {
+	Key     []string `json:"key"`
+	Columns []string `json:"columns"` // Non-key columns present in both tables, which are compared
+	Added   int64    `json:"added"`
+	Removed int64    `json:"removed"`
+	Changed int64    `json:"changed"`
+}
+
+// dataDiffPlan holds a connection to A with B attached, and the columns to compare.
+type dataDiffPlan struct {
+	conn   *sql.Conn
+	tableA string // Qualified, quoted table names
+	tableB string
+	keys   []string
+	cols   []string
+	dbs    []*sql.DB // Databases opened for the plan alone, closed with it
+}
+
+// planDataDiff resolves both tables and attaches B's database to a read-only
+// connection of A's, opened for the plan so that a long diff does not hold the
+// cached connection other queries of A wait for. The caller must close the plan.
+func (e *Engine) planDataDiff(ctx context.Context, opts DataDiffOptions) (*dataDiffPlan, error) {
+	pqA, err := e.prepareQuery(ctx, QueryOptions{BanquetPath: opts.A})
+	if err != nil {
+		return nil, err
+	}
+	pqB, err := e.prepareQuery(ctx, QueryOptions{BanquetPath: opts.B})
+	if err != nil {
+		return nil, err
+	}
+	pathA, err := e.resolveDBPath(pqA.dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	pathB, err := e.resolveDBPath(pqB.dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	// Connect the cached pool first: its connections switch the file to WAL,
+	// which waits for readers such as the diff in rollback journal mode
+	if err := pqA.db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+	dbA, err := openReadOnly(pathA)
+	if err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+	plan, err := newDataDiffPlan(ctx, dbA, pqA.bq.Table, pqB.db, pqB.bq.Table, "file:"+pathB+"?mode=ro", opts.Key)
+	if err != nil {
+		dbA.Close()
+		return nil, err
+	}
+	plan.dbs = []*sql.DB{dbA}
+	return plan, nil
+}
+
+// planDataDiffFiles is planDataDiff for paths of the form file[/table], opening
+// both files read-only.
+func planDataDiffFiles(ctx context.Context, opts DataDiffOptions) (*dataDiffPlan, error) {
+	dbs := make([]*sql.DB, 0, 2)
+	closeAll := func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}
+	paths, tables := make([]string, 2), make([]string, 2)
+	for i, p := range []string{opts.A, opts.B} {
+		var err error
+		if paths[i], tables[i], err = splitTablePath(p); err != nil {
+			closeAll()
+			return nil, err
+		}
+		db, err := openReadOnly(paths[i])
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("error opening DB: %w", err)
+		}
+		dbs = append(dbs, db)
+		if tables[i] == "" {
+			if tables[i], err = soleTable(ctx, db); err != nil {
+				closeAll()
+				return nil, err
+			}
+		}
+	}
+	plan, err := newDataDiffPlan(ctx, dbs[0], tables[0], dbs[1], tables[1], "file:"+paths[1]+"?mode=ro", opts.Key)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	plan.dbs = dbs
+	return plan, nil
+}
+
+// splitTablePath splits a path to a database file, optionally followed by a
+// table name, into the two.
+func splitTablePath(p string) (string, string, error) {
+	if info, err := os.Stat(p); err == nil && !info.IsDir() {
+		return p, "", nil
+	}
+	if info, err := os.Stat(filepath.Dir(p)); err == nil && !info.IsDir() {
+		return filepath.Dir(p), filepath.Base(p), nil
+	}
+	return "", "", fmt.Errorf("database not found: %s", p)
+}
+
+// newDataDiffPlan plans the comparison of tableA in dbA with tableB in dbB,
+// attaching the database file at pathB to a pinned connection of dbA.
+func newDataDiffPlan(ctx context.Context, dbA *sql.DB, tableA string, dbB *sql.DB, tableB, pathB string, keys []string) (*dataDiffPlan, error) {
+	colsA, err := tableColumns(ctx, dbA, tableA)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	colsB, err := tableColumns(ctx, dbB, tableB)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if len(keys) == 0 {
+		if keys, err = primaryKey(ctx, dbA, tableA); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+	}
+	for _, k := range keys {
+		if k == "rowid" {
+			continue
+		}
+		if !containsString(colsA, k) || !containsString(colsB, k) {
+			return nil, fmt.Errorf("key column %s must exist in both tables", k)
+		}
+	}
+
+	plan := &dataDiffPlan{
+		tableA: "main." + sqlite.QuoteIdentifier(tableA),
+		tableB: diffSchemaName + "." + sqlite.QuoteIdentifier(tableB),
+		keys:   keys,
+		cols:   make([]string, 0),
+	}
+	for _, c := range colsA {
+		if containsString(colsB, c) && !containsString(keys, c) {
+			plan.cols = append(plan.cols, c)
+		}
+	}
+
+	plan.conn, err = dbA.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+	if _, err := plan.conn.ExecContext(ctx, "ATTACH DATABASE ? AS "+diffSchemaName, pathB); err != nil {
+		plan.conn.Close()
+		return nil, fmt.Errorf("error attaching %s: %w", pathB, err)
+	}
+	return plan, nil
+}
+
+// close detaches B and returns the connection to the pool.
+func (p *dataDiffPlan) close() {
+	p.conn.ExecContext(context.Background(), "DETACH DATABASE "+diffSchemaName)
+	p.conn.Close()
+	for _, db := range p.dbs {
+		db.Close()
+	}
+}
+
+// run streams the removed, added and changed rows, each ordered by key.
+func (p *dataDiffPlan) run(ctx context.Context, onDiff func(RowDiff) error) (*DataDiffSummary, error) {
+	summary := &DataDiffSummary{Key: p.keys, Columns: p.cols}
+
+	quote := func(alias string, cols []string) []string {
+		out := make([]string, len(cols))
+		for i, c := range cols {
+			out[i] = alias + "." + sqlite.QuoteIdentifier(c)
+		}
+		return out
+	}
+	keysA, keysB := quote("a", p.keys), quote("b", p.keys)
+	match := make([]string, len(p.keys))
+	for i := range p.keys {
+		match[i] = fmt.Sprintf("%s = %s", keysB[i], keysA[i])
+	}
+	on := strings.Join(match, " AND ")
+
+	// Rows only in one table: removed rows are read from A, added rows from B
+	one := func(change, from, other, alias string, count *int64) error {
+		selects := append(quote(alias, p.keys), quote(alias, p.cols)...)
+		otherAlias := "b"
+		if alias == "b" {
+			otherAlias = "a"
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s %s WHERE NOT EXISTS (SELECT 1 FROM %s %s WHERE %s) ORDER BY %s",
+			strings.Join(selects, ", "), from, alias, other, otherAlias, on, strings.Join(quote(alias, p.keys), ", "))
+		return p.scan(ctx, query, len(selects), func(values []interface{}) error {
+			*count++
+			d := RowDiff{Change: change, Key: p.keyMap(values), Row: make(map[string]interface{}, len(p.cols))}
+			for i, c := range p.cols {
+				d.Row[c] = values[len(p.keys)+i]
+			}
+			return onDiff(d)
+		})
+	}
+	if err := one("removed", p.tableA, p.tableB, "a", &summary.Removed); err != nil {
+		return nil, err
+	}
+	if err := one("added", p.tableB, p.tableA, "b", &summary.Added); err != nil {
+		return nil, err
+	}
+	if len(p.cols) == 0 {
+		return summary, nil
+	}
+
+	// Rows in both: compare every shared column NULL-safely, selecting each
+	// column's old value, new value and whether they differ
+	selects := append([]string{}, keysA...)
+	conds := make([]string, len(p.cols))
+	colsA, colsB := quote("a", p.cols), quote("b", p.cols)
+	for i := range p.cols {
+		conds[i] = fmt.Sprintf("%s IS NOT %s", colsA[i], colsB[i])
+		selects = append(selects, colsA[i], colsB[i], conds[i])
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s a JOIN %s b ON %s WHERE %s ORDER BY %s",
+		strings.Join(selects, ", "), p.tableA, p.tableB, on, strings.Join(conds, " OR "), strings.Join(keysA, ", "))
+	err := p.scan(ctx, query, len(selects), func(values []interface{}) error {
+		summary.Changed++
+		d := RowDiff{Change: "changed", Key: p.keyMap(values)}
+		for i, c := range p.cols {
+			base := len(p.keys) + 3*i
+			if differs, _ := values[base+2].(int64); differs != 0 {
+				d.Columns = append(d.Columns, ColumnChange{Column: c, Before: values[base], After: values[base+1]})
+			}
+		}
+		return onDiff(d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// scan runs a query and calls fn with the normalized values of each row.
+func (p *dataDiffPlan) scan(ctx context.Context, query string, n int, fn func([]interface{}) error) error {
+	rows, err := p.conn.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, n)
+	ptrs := make([]interface{}, n)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("query error: %w", err)
+		}
+		out := make([]interface{}, n)
+		for i, v := range values {
+			out[i] = normalizeValue(v)
+		}
+		if err := fn(out); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (p *dataDiffPlan) keyMap(values []interface{}) map[string]interface{} {
+	key := make(map[string]interface{}, len(p.keys))
+	for i, k := range p.keys {
+		key[k] = values[i]
+	}
+	return key
+}
+
+// DiffData compares two tables row by row, matching rows on the key columns, and
+// calls onDiff for every removed, added and changed row. The comparison runs inside
+// SQLite with B's database attached to A's, so neither table is loaded into memory.
+func (e *Engine) DiffData(ctx context.Context, opts DataDiffOptions, onDiff func(RowDiff) error) (*DataDiffSummary, error) {
+	plan, err := e.planDataDiff(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer plan.close()
+	return plan.run(ctx, onDiff)
+}
+
+// ExportDataDiff writes the differences between two tables to w as JSON Lines (one
+// RowDiff per line) or CSV. The CSV has one line per differing column, or per
+// column of an added or removed row: change, the key columns, column, before, after.
+func (e *Engine) ExportDataDiff(ctx context.Context, opts DataDiffOptions, format string, w io.Writer) (*DataDiffSummary, error) {
+	if format != "jsonl" && format != "csv" {
+		return nil, fmt.Errorf("unsupported diff format: %s", format)
+	}
+	plan, err := e.planDataDiff(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer plan.close()
+	return plan.export(ctx, format, w)
+}
+
+// ExportDataDiffFiles is ExportDataDiff for two database files outside any
+// served folder. A and B are file paths, each optionally followed by /table.
+// Both files are opened read-only, leaving their journal mode untouched.
+func ExportDataDiffFiles(ctx context.Context, opts DataDiffOptions, format string, w io.Writer) (*DataDiffSummary, error) {
+	if format != "jsonl" && format != "csv" {
+		return nil, fmt.Errorf("unsupported diff format: %s", format)
+	}
+	plan, err := planDataDiffFiles(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer plan.close()
+	return plan.export(ctx, format, w)
+}
+
+// export writes the differences in format to w.
+func (p *dataDiffPlan) export(ctx context.Context, format string, w io.Writer) (*DataDiffSummary, error) {
+	if format == "jsonl" {
+		enc := json.NewEncoder(w)
+		return p.run(ctx, func(d RowDiff) error { return enc.Encode(d) })
+	}
+
+	cw := csv.NewWriter(w)
+	header := append(append([]string{"change"}, p.keys...), "column", "before", "after")
+	if err := cw.Write(header); err != nil {
+		return nil, err
+	}
+	cell := func(v interface{}) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("%v", v)
+	}
+	summary, err := p.run(ctx, func(d RowDiff) error {
+		record := []string{d.Change}
+		for _, k := range p.keys {
+			record = append(record, cell(d.Key[k]))
+		}
+		write := func(col string, before, after interface{}) error {
+			return cw.Write(append(append([]string{}, record...), col, cell(before), cell(after)))
+		}
+		// Added and removed rows list every column, keys included, so that
+		// rows of tables made only of key columns appear too
+		if d.Change != "changed" {
+			for _, c := range append(append([]string{}, p.keys...), p.cols...) {
+				v, ok := d.Row[c]
+				if !ok {
+					v = d.Key[c]
+				}
+				var err error
+				if d.Change == "removed" {
+					err = write(c, v, nil)
+				} else {
+					err = write(c, nil, v)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, c := range d.Columns {
+			if err := write(c.Column, c.Before, c.After); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return summary, err
+}
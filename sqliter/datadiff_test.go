@@ -0,0 +1,136 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestDiffData(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "v1.csv.db"), `
+		CREATE TABLE data (id INTEGER PRIMARY KEY, name TEXT, qty INTEGER, note TEXT);
+		INSERT INTO data VALUES (1, 'a', 1, NULL), (2, 'b', 2, 'x'), (3, 'c', 3, NULL);
+	`)
+	createTestDB(t, filepath.Join(tmpDir, "v2.csv.db"), `
+		CREATE TABLE data (id INTEGER PRIMARY KEY, name TEXT, qty INTEGER, extra TEXT);
+		INSERT INTO data VALUES (1, 'a', 1, 'new'), (2, 'b', NULL, NULL), (4, 'c', 3, NULL);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	var diffs []RowDiff
+	summary, err := engine.DiffData(ctx, DataDiffOptions{A: "/v1.csv.db/data", B: "/v2.csv.db/data"}, func(d RowDiff) error {
+		diffs = append(diffs, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DiffData failed: %v", err)
+	}
+	// Only name and qty are in both tables; row 1 is unchanged in those
+	if summary.Removed != 1 || summary.Added != 1 || summary.Changed != 1 || strings.Join(summary.Columns, ",") != "name,qty" {
+		t.Fatalf("Unexpected summary: %+v", summary)
+	}
+	changed := diffs[2]
+	if changed.Change != "changed" || changed.Key["id"] != int64(2) || len(changed.Columns) != 1 ||
+		changed.Columns[0].Column != "qty" || changed.Columns[0].Before != int64(2) || changed.Columns[0].After != nil {
+		t.Errorf("Expected qty of row 2 to change from 2 to NULL, got %+v", changed)
+	}
+
+	t.Run("CustomKey", func(t *testing.T) {
+		summary, err := engine.DiffData(ctx, DataDiffOptions{A: "/v1.csv.db/data", B: "/v2.csv.db/data", Key: []string{"name"}}, func(RowDiff) error { return nil })
+		if err != nil {
+			t.Fatalf("DiffData failed: %v", err)
+		}
+		// Matching on name, id becomes a compared column: c moved from id 3 to 4, b lost its qty
+		if summary.Removed != 0 || summary.Added != 0 || summary.Changed != 2 || strings.Join(summary.Columns, ",") != "id,qty" {
+			t.Errorf("Unexpected summary: %+v", summary)
+		}
+		if _, err := engine.DiffData(ctx, DataDiffOptions{A: "/v1.csv.db/data", B: "/v2.csv.db/data", Key: []string{"note"}}, nil); err == nil {
+			t.Error("Expected an error for a key missing from B")
+		}
+	})
+
+	t.Run("Queries during a diff", func(t *testing.T) {
+		_, err := engine.DiffData(ctx, DataDiffOptions{A: "/v1.csv.db/data", B: "/v2.csv.db/data"}, func(RowDiff) error {
+			qctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			_, err := engine.Query(qctx, QueryOptions{BanquetPath: "/v1.csv.db/data"})
+			return err
+		})
+		if err != nil {
+			t.Errorf("Expected A to stay queryable while diffing, got %v", err)
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/diff/data?a=/v1.csv.db/data&b=/v2.csv.db&format=jsonl", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		var first RowDiff
+		if len(lines) != 3 || json.Unmarshal([]byte(lines[0]), &first) != nil || first.Change != "removed" {
+			t.Errorf("Expected 3 JSON lines starting with the removed row, got:\n%s", w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/diff/data?a=/v1.csv.db/data&b=/v2.csv.db/data&format=csv", nil))
+		want := "change,id,column,before,after\nremoved,3,id,3,\nremoved,3,name,c,\nremoved,3,qty,3,\nadded,4,id,,4\nadded,4,name,,c\nadded,4,qty,,3\nchanged,2,qty,2,\n"
+		if w.Body.String() != want {
+			t.Errorf("Unexpected CSV:\n%s", w.Body.String())
+		}
+	})
+}
+
+func TestExportDataDiffFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	a, b := filepath.Join(tmpDir, "a.db"), filepath.Join(tmpDir, "b.db")
+	// Tags has only key columns
+	createTestDB(t, a, `
+		CREATE TABLE tags (name TEXT PRIMARY KEY);
+		INSERT INTO tags VALUES ('red'), ('green');
+	`)
+	createTestDB(t, b, `
+		CREATE TABLE tags (name TEXT PRIMARY KEY);
+		CREATE TABLE other (x);
+		INSERT INTO tags VALUES ('green'), ('blue');
+	`)
+
+	var out bytes.Buffer
+	summary, err := ExportDataDiffFiles(context.Background(), DataDiffOptions{A: a, B: filepath.Join(b, "tags")}, "csv", &out)
+	if err != nil {
+		t.Fatalf("ExportDataDiffFiles failed: %v", err)
+	}
+	if want := "change,name,column,before,after\nremoved,red,name,red,\nadded,blue,name,,blue\n"; out.String() != want {
+		t.Errorf("Unexpected CSV:\n%s", out.String())
+	}
+	if summary.Added != 1 || summary.Removed != 1 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	for _, p := range []string{a, b} {
+		if _, err := os.Stat(p + "-wal"); err == nil {
+			t.Errorf("Expected no WAL file next to %s", p)
+		}
+	}
+
+	if _, err := ExportDataDiffFiles(context.Background(), DataDiffOptions{A: a, B: b}, "csv", &out); err == nil {
+		t.Errorf("Expected an error when a table is ambiguous")
+	}
+	if _, err := ExportDataDiffFiles(context.Background(), DataDiffOptions{A: a, B: filepath.Join(tmpDir, "missing.db", "tags")}, "csv", &out); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
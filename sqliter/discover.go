@@ -0,0 +1,321 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSearchDepth is how many directory levels FindDatabases descends when no depth is given.
+	DefaultSearchDepth = 5
+	// DefaultSearchLimit caps the number of databases returned by FindDatabases.
+	DefaultSearchLimit = 100
+)
+
+// DefaultSearchIgnore lists directory names that are never descended into.
+// Hidden entries (dot files and dot directories) are always skipped, like in ListFiles.
+var DefaultSearchIgnore = []string{"node_modules", "vendor", "__pycache__"}
+
+// SearchOptions controls a recursive database search below ServeFolder.
+type SearchOptions struct {
+	Query    string   // Case-insensitive substring matched against file, table and column names
+	Dir      string   // Directory to start from, relative to ServeFolder
+	MaxDepth int      // Directory levels to descend; <= 0 uses DefaultSearchDepth
+	Ignore   []string // Glob patterns of names to skip; nil uses DefaultSearchIgnore
+	Limit    int      // Maximum number of results; <= 0 uses DefaultSearchLimit
+}
+
+// TableMatch is a table whose name or columns matched a search.
+type TableMatch struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Columns []string `json:"columns,omitempty"` // Matched columns only
+}
+
+// DatabaseMatch is a database file found by FindDatabases.
+type DatabaseMatch struct {
+	Path        string       `json:"path"` // Relative to ServeFolder, slash separated
+	Name        string       `json:"name"`
+	NameMatched bool         `json:"nameMatched"`
+	Tables      []TableMatch `json:"tables,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// isDatabaseFile reports whether a file name looks like a servable SQLite database.
+func isDatabaseFile(name string) bool {
+	return strings.HasSuffix(name, ".db") || strings.HasSuffix(name, ".sqlite") ||
+		strings.HasSuffix(name, ".csv.db") || strings.HasSuffix(name, ".xlsx.db")
+}
+
+// dbIndex caches directory walks and database schemas for FindDatabases.
+// Walks are reused until the mtime of any visited directory changes, and
+// schemas are reused until the database file's mtime or size changes.
+type dbIndex struct {
+	mu      sync.Mutex
+	trees   map[string]*treeSnapshot
+	schemas map[string]*schemaSnapshot
+}
+
+type treeSnapshot struct {
+	dirs  map[string]time.Time // Visited directory -> mtime at walk time
+	files []string             // Absolute database paths, sorted
+}
+
+type schemaSnapshot struct {
+	modTime time.Time
+	size    int64
+	tables  []tableSchema
+	err     error
+}
+
+type tableSchema struct {
+	name    string
+	typ     string
+	columns []string
+}
+
+func newDBIndex() *dbIndex {
+	return &dbIndex{
+		trees:   make(map[string]*treeSnapshot),
+		schemas: make(map[string]*schemaSnapshot),
+	}
+}
+
+// stale reports whether any directory covered by the snapshot has changed.
+func (t *treeSnapshot) stale() bool {
+	for dir, modTime := range t.dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindDatabases walks ServeFolder (or a subdirectory of it) and returns the
+// databases whose file name, table names or column names match opts.Query.
+// An empty query returns every database found.
+func (e *Engine) FindDatabases(ctx context.Context, opts SearchOptions) ([]DatabaseMatch, error) {
+	if strings.Contains(opts.Dir, "..") {
+		return nil, fmt.Errorf("invalid path")
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultSearchDepth
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultSearchLimit
+	}
+	if opts.Ignore == nil {
+		opts.Ignore = DefaultSearchIgnore
+	}
+
+	root := filepath.Join(e.config.ServeFolder, opts.Dir)
+	files, err := e.index.databases(ctx, root, opts.MaxDepth, opts.Ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.ToLower(strings.TrimSpace(opts.Query))
+	results := make([]DatabaseMatch, 0)
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(results) >= opts.Limit {
+			break
+		}
+
+		rel, err := filepath.Rel(e.config.ServeFolder, file)
+		if err != nil {
+			continue
+		}
+		match := DatabaseMatch{
+			Path: filepath.ToSlash(rel),
+			Name: filepath.Base(file),
+		}
+		match.NameMatched = query == "" || strings.Contains(strings.ToLower(match.Name), query)
+
+		if query == "" {
+			results = append(results, match)
+			continue
+		}
+
+		tables, err := e.index.schema(ctx, file)
+		if err != nil {
+			if match.NameMatched {
+				match.Error = err.Error()
+				results = append(results, match)
+			}
+			continue
+		}
+
+		for _, tbl := range tables {
+			tm := TableMatch{Name: tbl.name, Type: tbl.typ}
+			tableMatched := strings.Contains(strings.ToLower(tbl.name), query)
+			for _, col := range tbl.columns {
+				if strings.Contains(strings.ToLower(col), query) {
+					tm.Columns = append(tm.Columns, col)
+				}
+			}
+			if tableMatched || len(tm.Columns) > 0 {
+				match.Tables = append(match.Tables, tm)
+			}
+		}
+
+		if match.NameMatched || len(match.Tables) > 0 {
+			results = append(results, match)
+		}
+	}
+	return results, nil
+}
+
+// databases returns the database files below root, reusing the previous walk
+// if no directory in it has changed since.
+func (x *dbIndex) databases(ctx context.Context, root string, maxDepth int, ignore []string) ([]string, error) {
+	key := fmt.Sprintf("%s|%d|%s", root, maxDepth, strings.Join(ignore, ","))
+
+	x.mu.Lock()
+	snap, ok := x.trees[key]
+	x.mu.Unlock()
+	if ok && !snap.stale() {
+		return snap.files, nil
+	}
+
+	snap, err := walkDatabases(ctx, root, maxDepth, ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.Lock()
+	x.trees[key] = snap
+	x.mu.Unlock()
+	return snap.files, nil
+}
+
+func walkDatabases(ctx context.Context, root string, maxDepth int, ignore []string) (*treeSnapshot, error) {
+	snap := &treeSnapshot{dirs: make(map[string]time.Time)}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the whole search
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		name := d.Name()
+		if path != root && (strings.HasPrefix(name, ".") || ignored(name, ignore)) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				snap.dirs[path] = info.ModTime()
+			}
+			rel, _ := filepath.Rel(root, path)
+			if rel != "." && strings.Count(rel, string(filepath.Separator))+1 >= maxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if isDatabaseFile(name) {
+			snap.files = append(snap.files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	sort.Strings(snap.files)
+	return snap, nil
+}
+
+func ignored(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// schema returns the tables and columns of a database file, reading them only
+// when the file is new or has changed on disk.
+func (x *dbIndex) schema(ctx context.Context, path string) ([]tableSchema, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.Lock()
+	snap, ok := x.schemas[path]
+	x.mu.Unlock()
+	if ok && snap.modTime.Equal(info.ModTime()) && snap.size == info.Size() {
+		return snap.tables, snap.err
+	}
+
+	tables, err := readSchema(ctx, path)
+	x.mu.Lock()
+	x.schemas[path] = &schemaSnapshot{modTime: info.ModTime(), size: info.Size(), tables: tables, err: err}
+	x.mu.Unlock()
+	return tables, err
+}
+
+// openReadOnly opens a short-lived, read-only connection that is not added to the
+// engine's connection cache. Callers must close it.
+func openReadOnly(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func readSchema(ctx context.Context, path string) ([]tableSchema, error) {
+	db, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT m.name, m.type, p.name
+		FROM sqlite_master m JOIN pragma_table_info(m.name) p
+		WHERE m.type IN ('table', 'view')
+		ORDER BY m.name, p.cid`)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []tableSchema
+	for rows.Next() {
+		var table, typ, column string
+		if err := rows.Scan(&table, &typ, &column); err != nil {
+			continue
+		}
+		if len(tables) == 0 || tables[len(tables)-1].name != table {
+			tables = append(tables, tableSchema{name: table, typ: typ})
+		}
+		last := &tables[len(tables)-1]
+		last.columns = append(last.columns, column)
+	}
+	return tables, rows.Err()
+}
@@ -0,0 +1,112 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func createTestDB(t *testing.T, path string, schema string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("Failed to setup db %s: %v", path, err)
+	}
+}
+
+func TestFindDatabases(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "sales", "2024", "orders.db"), "CREATE TABLE orders (id INTEGER, customer_id INTEGER);")
+	createTestDB(t, filepath.Join(tmpDir, "crm.db"), "CREATE TABLE customers (id INTEGER, email TEXT);")
+	createTestDB(t, filepath.Join(tmpDir, "node_modules", "orders.db"), "CREATE TABLE orders (id INTEGER);")
+	createTestDB(t, filepath.Join(tmpDir, ".hidden", "orders.db"), "CREATE TABLE orders (id INTEGER);")
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	ctx := context.Background()
+
+	t.Run("All databases", func(t *testing.T) {
+		matches, err := engine.FindDatabases(ctx, SearchOptions{})
+		if err != nil {
+			t.Fatalf("FindDatabases failed: %v", err)
+		}
+		if len(matches) != 2 {
+			t.Fatalf("Expected 2 databases (ignored dirs skipped), got %d: %+v", len(matches), matches)
+		}
+		if matches[0].Path != "crm.db" || matches[1].Path != "sales/2024/orders.db" {
+			t.Errorf("Unexpected paths: %+v", matches)
+		}
+	})
+
+	t.Run("Match by column", func(t *testing.T) {
+		matches, err := engine.FindDatabases(ctx, SearchOptions{Query: "customer"})
+		if err != nil {
+			t.Fatalf("FindDatabases failed: %v", err)
+		}
+		if len(matches) != 2 {
+			t.Fatalf("Expected 2 matches, got %d: %+v", len(matches), matches)
+		}
+		orders := matches[1]
+		if orders.NameMatched || len(orders.Tables) != 1 || orders.Tables[0].Columns[0] != "customer_id" {
+			t.Errorf("Expected column match on orders.customer_id, got %+v", orders)
+		}
+	})
+
+	t.Run("Depth limit", func(t *testing.T) {
+		matches, err := engine.FindDatabases(ctx, SearchOptions{MaxDepth: 2})
+		if err != nil {
+			t.Fatalf("FindDatabases failed: %v", err)
+		}
+		if len(matches) != 1 {
+			t.Errorf("Expected only crm.db within depth 2, got %+v", matches)
+		}
+	})
+
+	t.Run("Cache refreshes on tree change", func(t *testing.T) {
+		before, _ := engine.FindDatabases(ctx, SearchOptions{Query: "inventory"})
+		if len(before) != 0 {
+			t.Fatalf("Expected no inventory matches yet, got %+v", before)
+		}
+		// Ensure the directory mtime moves even on coarse-grained filesystems
+		time.Sleep(10 * time.Millisecond)
+		createTestDB(t, filepath.Join(tmpDir, "sales", "inventory.db"), "CREATE TABLE stock (sku TEXT);")
+		after, err := engine.FindDatabases(ctx, SearchOptions{Query: "inventory"})
+		if err != nil {
+			t.Fatalf("FindDatabases failed: %v", err)
+		}
+		if len(after) != 1 || after[0].Path != "sales/inventory.db" {
+			t.Errorf("Expected new database to be found, got %+v", after)
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		req := httptest.NewRequest("GET", "/sqliter/fs/search?q=email", nil)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var matches []DatabaseMatch
+		if err := json.NewDecoder(w.Body).Decode(&matches); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(matches) != 1 || matches[0].Path != "crm.db" {
+			t.Errorf("Expected crm.db, got %+v", matches)
+		}
+	})
+}
@@ -3,7 +3,9 @@ package sqliter
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -21,23 +23,67 @@ type Engine struct {
 
 	mu    sync.Mutex
 	conns map[string]*sql.DB
+
+	index    *dbIndex
+	workload *workload
+	counts   *countCache
+	links    *linkCache
+	results  *resultCache // Nil when Config.ResultCacheBytes is 0
+
+	catalogMu sync.Mutex
+	catalog   *Catalog
+
+	historyMu    sync.Mutex
+	historyQueue chan HistoryEntry // Nil until a query is recorded
+	historyFlush chan chan struct{}
+	historyDone  chan struct{}
 }
 
+// ErrReadOnly is returned by the Engine methods that modify served databases
+// when Config.Writable is off.
+var ErrReadOnly = errors.New("server is read-only")
+
 func NewEngine(cfg *Config) *Engine {
-	return &Engine{
-		config: cfg,
-		conns:  make(map[string]*sql.DB),
+	e := &Engine{
+		config:   cfg,
+		conns:    make(map[string]*sql.DB),
+		index:    newDBIndex(),
+		workload: newWorkload(),
+		counts:   newCountCache(),
+		links:    newLinkCache(),
+	}
+	if cfg.ResultCacheBytes > 0 {
+		e.results = newResultCache(cfg.ResultCacheBytes)
+	}
+	return e
+}
+
+// checkWritable returns ErrReadOnly unless Config.Writable is on.
+func (e *Engine) checkWritable() error {
+	if !e.config.Writable {
+		return ErrReadOnly
 	}
+	return nil
 }
 
-// CloseAll closes all cached database connections
+// CloseAll closes all cached database connections and the catalog
 func (e *Engine) CloseAll() {
+	e.stopHistory()
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	for path, db := range e.conns {
 		db.Close()
 		delete(e.conns, path)
 	}
+	e.ClearCache()
+
+	e.catalogMu.Lock()
+	defer e.catalogMu.Unlock()
+	if e.catalog != nil {
+		e.catalog.Close()
+		e.catalog = nil
+	}
 }
 
 // getDBConnection returns a cached connection or opens a new one
@@ -73,6 +119,86 @@ func (e *Engine) getDBConnection(ctx context.Context, dbPath string) (*sql.DB, e
 	return db, nil
 }
 
+// resolveDBPath maps a database path relative to ServeFolder onto the filesystem.
+func (e *Engine) resolveDBPath(dbRelPath string) (string, error) {
+	if strings.Contains(dbRelPath, "..") {
+		return "", fmt.Errorf("invalid path")
+	}
+	return filepath.Join(e.config.ServeFolder, strings.TrimPrefix(dbRelPath, "/")), nil
+}
+
+// openDB returns the cached connection for a database path relative to ServeFolder.
+func (e *Engine) openDB(ctx context.Context, dbRelPath string) (*sql.DB, error) {
+	dbPath, err := e.resolveDBPath(dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	db, err := e.getDBConnection(ctx, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+	return db, nil
+}
+
+// execTx runs statements in a single transaction, rolling back on the first failure.
+func execTx(ctx context.Context, db *sql.DB, stmts []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// BanquetPath builds a Banquet path for a table, optionally filtered by a WHERE fragment.
+func BanquetPath(dbRelPath, table, where string) string {
+	p := "/" + strings.TrimPrefix(filepath.ToSlash(dbRelPath), "/") + "/" + url.PathEscape(table)
+	if where != "" {
+		p += "?where=" + url.QueryEscape(where)
+	}
+	return p
+}
+
+// quoteLiteral renders s as a SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sqlLiteral renders a scanned or JSON-decoded value as a SQL literal.
+func sqlLiteral(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteLiteral(val), nil
+	case []byte:
+		return quoteLiteral(string(val)), nil
+	case int, int64, float64:
+		return fmt.Sprintf("%v", val), nil
+	case bool:
+		if val {
+			return "1", nil
+		}
+		return "0", nil
+	default:
+		return "", fmt.Errorf("unsupported value type: %T", v)
+	}
+}
+
 type FileEntry struct {
 	Name string `json:"name"`
 	Type string `json:"type"`
@@ -101,8 +227,7 @@ func (e *Engine) ListFiles(ctx context.Context, dirRelPath string) ([]FileEntry,
 			files = append(files, FileEntry{Name: name, Type: "directory"})
 		} else {
 			// Check extension
-			if strings.HasSuffix(name, ".db") || strings.HasSuffix(name, ".sqlite") ||
-				strings.HasSuffix(name, ".csv.db") || strings.HasSuffix(name, ".xlsx.db") {
+			if isDatabaseFile(name) {
 				files = append(files, FileEntry{Name: name, Type: "database"})
 			}
 		}
@@ -145,29 +270,92 @@ func (e *Engine) ListTables(ctx context.Context, dbRelPath string) ([]TableInfo,
 	return tables, nil
 }
 
+// soleTable returns the only table or view of a database, which paths
+// without a table select.
+func soleTable(ctx context.Context, db *sql.DB) (string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type IN ('table', 'view') ORDER BY name")
+	if err != nil {
+		return "", fmt.Errorf("failed to list tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+
+	if len(tables) == 1 {
+		return tables[0], nil
+	} else if len(tables) == 0 {
+		return "", fmt.Errorf("no tables found in database")
+	}
+	return "", fmt.Errorf("table name required. Available tables: %s", strings.Join(tables, ", "))
+}
+
 type QueryOptions struct {
 	BanquetPath     string
 	FilterWhere     string // SQL fragment
 	FilterModelJSON string // AgGrid Filter Model JSON
+	QuickFilter     string // Free text matched across the table, via its FTS5 index when one exists
 	SortCol         string
 	SortDir         string
 	Offset          int
 	Limit           int
-	ForceZeroLimit  bool // If true, explicitly set Limit to 0
-	AllowOverride   bool // If true, Limit/Offset in options override BanquetPath defaults
-	SkipTotalCount  bool // If true, skips the COUNT(*) query for performance
+	ForceZeroLimit  bool              // If true, explicitly set Limit to 0
+	AllowOverride   bool              // If true, Limit/Offset in options override BanquetPath defaults
+	SkipTotalCount  bool              // If true, skips the COUNT(*) query for performance
+	CountStrategy   string            // CountExact (default), CountCached or CountEstimated
+	TypedValues     bool              // If true, values are TypedValue envelopes carrying their storage class
+	Attach          map[string]string // Alias -> database path relative to ServeFolder, queryable as alias.table
+
+	// Aggregation mode. When GroupBy is set, rows are grouped and Aggregates
+	// (count(*) if empty) are computed per group instead of returning rows.
+	GroupBy    []string
+	Aggregates []Aggregate
+	GroupKeys  []interface{} // Values of the leading GroupBy columns, restricting results to one group
+	Drilldown  bool          // Treat GroupBy as a hierarchy: only the level below GroupKeys is grouped, and a fully keyed group returns its rows
 }
 
 type QueryResult struct {
-	Columns    []string        `json:"columns"`
-	Values     [][]interface{} `json:"values"`
-	TotalCount int             `json:"totalCount"`
-	SQL        string          `json:"sql"`
+	Columns     []string        `json:"columns"`
+	ColumnTypes []string        `json:"columnTypes,omitempty"` // Declared SQLite types, "" for expressions
+	Values      [][]interface{} `json:"values"`
+	TotalCount  int             `json:"totalCount"`
+	CountType   string          `json:"countType,omitempty"` // CountTypeExact or CountTypeEstimated; empty when the count is skipped
+	SQL         string          `json:"sql"`
+	Links       []ColumnLink    `json:"links,omitempty"`       // Foreign-key columns that can be rendered as links
+	RowIDColumn string          `json:"rowidColumn,omitempty"` // Column holding the rowid, set with TypedValues, to address cells such as BLOBs
+	Truncated   bool            `json:"truncated,omitempty"`   // More rows were available than returned
+	Cached      bool            `json:"cached,omitempty"`      // Served from the result cache
 }
 
-func (e *Engine) Query(ctx context.Context, opts QueryOptions) (*QueryResult, error) {
-	start := time.Now()
-	last := start
+// preparedQuery is a QueryOptions request resolved against its database and
+// composed into the SQL that Query and QueryStream execute.
+type preparedQuery struct {
+	bq         *banquet.Banquet
+	db         *sql.DB
+	dbRelPath  string
+	query      string
+	countQuery string
+	timeTypes  map[string]string // Declared types of columns rewritten by rawTimeQuery
+	withRowID  bool              // The rowid is selected after the columns, see rowIDQuery
+	ownDB      bool              // db was opened by openAttached for this query alone
+}
+
+// close closes the query's connection when it is not a cached one.
+func (pq *preparedQuery) close() {
+	if pq.ownDB {
+		pq.db.Close()
+	}
+}
+
+// prepareQuery parses the Banquet path, applies the option overrides and filters,
+// opens the database and composes the page and count queries. Callers close the
+// query once its statements are done, as its connection may be its own.
+func (e *Engine) prepareQuery(ctx context.Context, opts QueryOptions) (*preparedQuery, error) {
+	last := time.Now()
 
 	bq, err := banquet.ParseNested(opts.BanquetPath)
 	if err != nil {
@@ -217,223 +405,373 @@ func (e *Engine) Query(ctx context.Context, opts QueryOptions) (*QueryResult, er
 	}
 
 	dataSetPath := strings.TrimPrefix(bq.DataSetPath, "/")
-	if strings.Contains(dataSetPath, "..") {
-		return nil, fmt.Errorf("invalid path")
+	dbPath, err := e.resolveDBPath(dataSetPath)
+	if err != nil {
+		return nil, err
 	}
 
-	dbPath := filepath.Join(e.config.ServeFolder, dataSetPath)
-
-	// Use cached connection
-	db, err := e.getDBConnection(ctx, dbPath)
+	// Use cached connection, unless databases are attached
+	var db *sql.DB
+	if len(opts.Attach) > 0 {
+		db, err = e.openAttached(ctx, dbPath, opts.Attach)
+	} else if db, err = e.getDBConnection(ctx, dbPath); err != nil {
+		err = fmt.Errorf("error opening DB: %w", err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error opening DB: %w", err)
+		return nil, err
 	}
+	pq := &preparedQuery{bq: bq, db: db, dbRelPath: dataSetPath, ownDB: len(opts.Attach) > 0}
 
 	fmt.Printf("[Engine.Query] DB Open/Fetch took %v\n", time.Since(last))
-	last = time.Now()
 
-	// Optimizations handled in DSN
-	// last = time.Now()
+	if err := pq.compose(ctx, opts); err != nil {
+		pq.close()
+		return nil, err
+	}
+	return pq, nil
+}
+
+// compose composes the page and count queries of opts on pq's database.
+func (pq *preparedQuery) compose(ctx context.Context, opts QueryOptions) error {
+	bq, db := pq.bq, pq.db
+	var err error
 
 	// Handle case where table name is missing
 	if bq.Table == "" {
-		rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type IN ('table', 'view') ORDER BY name")
+		if bq.Table, err = soleTable(ctx, db); err != nil {
+			return err
+		}
+	}
+
+	rankJoin := ""
+	if opts.QuickFilter != "" {
+		rankJoin, err = applyQuickFilter(ctx, db, bq, opts.QuickFilter)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list tables: %w", err)
+			return fmt.Errorf("error building quick filter: %w", err)
 		}
-		var tables []string
-		for rows.Next() {
-			var name string
-			if err := rows.Scan(&name); err == nil {
-				tables = append(tables, name)
+	}
+
+	if len(opts.GroupBy) > 0 {
+		if err := checkAggregateColumns(ctx, db, bq.Table, opts.GroupBy, opts.Aggregates); err != nil {
+			return err
+		}
+		if err := applyGroupKeys(bq, opts.GroupBy, opts.GroupKeys); err != nil {
+			return fmt.Errorf("error building group filter: %w", err)
+		}
+		groupCols := opts.GroupBy
+		if opts.Drilldown {
+			groupCols = nil
+			if level := len(opts.GroupKeys); level < len(opts.GroupBy) {
+				groupCols = opts.GroupBy[level : level+1]
 			}
 		}
-		rows.Close()
-
-		if len(tables) == 1 {
-			bq.Table = tables[0]
-		} else if len(tables) == 0 {
-			return nil, fmt.Errorf("no tables found in database")
-		} else {
-			return nil, fmt.Errorf("table name required. Available tables: %s", strings.Join(tables, ", "))
+		if len(groupCols) > 0 {
+			pq.query, pq.countQuery, err = composeAggregate(bq, groupCols, opts.Aggregates)
+			if err != nil {
+				return fmt.Errorf("error building aggregate: %w", err)
+			}
+			return nil
 		}
 	}
 
-	query := sqlite.Compose(bq)
-
-	// Get total count
-	var totalCount int = -1
-	if !opts.SkipTotalCount {
-		countStart := time.Now()
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s", sqlite.QuoteIdentifier(bq.Table))
-		if bq.Where != "" {
-			countQuery += " WHERE " + bq.Where
-		}
-		_ = db.QueryRowContext(ctx, countQuery).Scan(&totalCount)
-		fmt.Printf("[Engine.Query] TotalCount query took %v\n", time.Since(countStart))
+	if rankJoin != "" && bq.OrderBy == "" {
+		pq.query = rankedQuery(bq, rankJoin)
 	} else {
-		fmt.Printf("[Engine.Query] TotalCount SKIPPED\n")
+		pq.query = sqlite.Compose(bq)
+	}
+	if opts.TypedValues {
+		pq.query, pq.timeTypes = rawTimeQuery(ctx, db, bq, pq.query)
+		pq.query, pq.withRowID = rowIDQuery(ctx, db, bq.Table, pq.query)
 	}
-	last = time.Now()
 
-	rows, err := db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, fmt.Errorf("query error: %w", err)
+	pq.countQuery = fmt.Sprintf("SELECT COUNT(*) FROM %s", sqlite.QuoteIdentifier(bq.Table))
+	if bq.Where != "" {
+		pq.countQuery += " WHERE " + bq.Where
 	}
-	defer rows.Close()
-	fmt.Printf("[Engine.Query] Main Query Exec took %v\n", time.Since(last))
-	last = time.Now()
 
+	return nil
+}
+
+// columns returns the columns of a page query, without the rowid selected
+// after them.
+func (pq *preparedQuery) columns(rows *sql.Rows) ([]string, error) {
 	columns, err := rows.Columns()
-	if err != nil {
-		return nil, fmt.Errorf("error getting columns: %w", err)
+	if err == nil && pq.withRowID {
+		columns = columns[:len(columns)-1]
 	}
+	return columns, err
+}
 
-	resp := &QueryResult{
-		Columns:    columns,
-		TotalCount: totalCount,
-		SQL:        query,
-		Values:     make([][]interface{}, 0),
+// columnTypes returns the declared types of a page query's columns.
+func (pq *preparedQuery) columnTypes(rows *sql.Rows, columns []string) []string {
+	types := resultColumnTypes(rows)
+	if len(types) > len(columns) {
+		types = types[:len(columns)]
 	}
+	for i, name := range columns {
+		if decl, ok := pq.timeTypes[name]; ok && i < len(types) {
+			types[i] = decl
+		}
+	}
+	return types
+}
 
-	values := make([]interface{}, len(columns))
-	valuePtrs := make([]interface{}, len(columns))
-	for i := range values {
-		valuePtrs[i] = &values[i]
+// Query runs a Banquet query and records it in the query history.
+func (e *Engine) Query(ctx context.Context, opts QueryOptions) (*QueryResult, error) {
+	start := time.Now()
+	res, err := e.query(ctx, opts)
+	h := HistoryEntry{Kind: "query", BanquetPath: opts.BanquetPath}
+	if res != nil {
+		h.SQL, h.RowCount = res.SQL, int64(len(res.Values))
 	}
+	e.recordHistory(h, opts, start, err)
+	return res, err
+}
 
-	for rows.Next() {
-		if err := rows.Scan(valuePtrs...); err != nil {
-			continue
-		}
+func (e *Engine) query(ctx context.Context, opts QueryOptions) (*QueryResult, error) {
+	page, cached, err := e.openPage(ctx, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		res := *cached.result
+		res.Values = copyValues(cached.result.Values)
+		res.Cached = true
+		return &res, nil
+	}
+	defer page.close()
+	last := time.Now()
 
-		rowData := make([]interface{}, len(columns))
-		for i := range columns {
-			val := values[i]
-			if b, ok := val.([]byte); ok {
-				rowData[i] = string(b)
-			} else {
-				rowData[i] = val
-			}
+	resp := page.res
+	resp.Values = make([][]interface{}, 0)
+	values, valuePtrs := page.pq.scanBuffers(resp.Columns)
+	for page.rows.Next() {
+		if err := page.rows.Scan(valuePtrs...); err != nil {
+			continue
 		}
-		resp.Values = append(resp.Values, rowData)
+		resp.Values = append(resp.Values, page.pq.row(values, resp.Columns, opts.TypedValues))
 	}
 	fmt.Printf("[Engine.Query] Row Scan took %v\n", time.Since(last))
-	fmt.Printf("[Engine.Query] TOTAL DURATION: %v\n", time.Since(start))
-
+	var entry *cachedResult
+	if page.cacheKey != "" && page.rows.Err() == nil {
+		stored := *resp
+		stored.Values = copyValues(resp.Values)
+		entry = &cachedResult{result: &stored}
+	}
+	page.finish(e, entry)
 	return resp, nil
 }
 
-type QueryResultChunk struct {
-	Columns    []string        `json:"columns,omitempty"`
-	Values     [][]interface{} `json:"values"`
-	TotalCount int             `json:"totalCount,omitempty"`
-	SQL        string          `json:"sql,omitempty"`
-	Error      string          `json:"error,omitempty"`
+// pageQuery is a page query whose rows are being read.
+type pageQuery struct {
+	pq    *preparedQuery
+	res   *QueryResult // Result metadata; rows are added by the reader
+	rows  *sql.Rows
+	start time.Time
+
+	cacheKey string // Empty when the result is not cached
+	version  string
 }
 
-// QueryStream executes a query and calls key callbacks during execution.
-func (e *Engine) QueryStream(ctx context.Context, opts QueryOptions, onChunk func(QueryResultChunk)) error {
+// openPage prepares and runs a page query, up to the point where its rows can
+// be read. A valid cached result is returned instead when there is one; encoded
+// selects the entries QueryJSON stores, which hold JSON rather than Values.
+func (e *Engine) openPage(ctx context.Context, opts QueryOptions, encoded bool) (*pageQuery, *cachedResult, error) {
 	start := time.Now()
+	last := start
 
-	// --- 1. Query Preparation (Identical to Query) ---
-	bq, err := banquet.ParseNested(opts.BanquetPath)
+	pq, err := e.prepareQuery(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("error parsing URL: %w", err)
+		return nil, nil, err
 	}
+	db := pq.db
+	query := pq.query
 
-	// Override limit/offset if provided
-	if opts.AllowOverride {
-		if opts.Limit > 0 {
-			bq.Limit = fmt.Sprintf("%d", opts.Limit)
-			bq.Offset = fmt.Sprintf("%d", opts.Offset)
-		} else if opts.ForceZeroLimit {
-			bq.Limit = "0"
-			bq.Offset = fmt.Sprintf("%d", opts.Offset)
+	fmt.Printf("[Engine.Query] Prepare took %v\n", time.Since(last))
+	last = time.Now()
+
+	// Results of queries on attached databases are not cached, as only the main
+	// database's version is tracked
+	page := &pageQuery{pq: pq, start: start}
+	if e.results != nil && len(opts.Attach) == 0 {
+		if page.version, err = e.DatabaseVersion(ctx, pq.dbRelPath); err == nil {
+			page.cacheKey = resultCacheKey(pq, opts)
+			if encoded {
+				page.cacheKey += "\x00json"
+			}
+			if cached, ok := e.results.get(page.cacheKey, page.version); ok {
+				fmt.Printf("[Engine.Query] Served from cache in %v\n", time.Since(start))
+				pq.close()
+				return nil, cached, nil
+			}
 		}
 	}
 
-	if opts.SortCol != "" {
-		bq.OrderBy = opts.SortCol
-		if opts.SortDir != "" {
-			bq.SortDirection = opts.SortDir
-		}
+	// Get total count
+	totalCount, countType := e.totalCount(ctx, pq, opts)
+	if !opts.SkipTotalCount {
+		fmt.Printf("[Engine.Query] TotalCount (%s) took %v\n", opts.CountStrategy, time.Since(last))
+	} else {
+		fmt.Printf("[Engine.Query] TotalCount SKIPPED\n")
 	}
+	last = time.Now()
 
-	if opts.FilterModelJSON != "" {
-		fmWhere, err := BuildWhereClause(opts.FilterModelJSON)
-		if err != nil {
-			return fmt.Errorf("error building filter: %w", err)
-		}
-		if fmWhere != "" {
-			if opts.FilterWhere != "" {
-				opts.FilterWhere = fmt.Sprintf("(%s) AND (%s)", opts.FilterWhere, fmWhere)
-			} else {
-				opts.FilterWhere = fmWhere
-			}
-		}
+	// Link metadata is best effort, and must be read before the main query
+	// occupies the database's single connection
+	links := e.tableLinks(ctx, db, pq.dbRelPath, pq.bq.Table)
+	var rowidCol string
+	if opts.TypedValues && len(opts.GroupBy) == 0 {
+		rowidCol = rowIDColumn(ctx, db, pq.bq.Table)
 	}
 
-	if opts.FilterWhere != "" {
-		if bq.Where != "" {
-			bq.Where = fmt.Sprintf("(%s) AND (%s)", bq.Where, opts.FilterWhere)
-		} else {
-			bq.Where = opts.FilterWhere
-		}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		pq.close()
+		return nil, nil, fmt.Errorf("query error: %w", err)
 	}
+	fmt.Printf("[Engine.Query] Main Query Exec took %v\n", time.Since(last))
+	page.rows = rows
 
-	dataSetPath := strings.TrimPrefix(bq.DataSetPath, "/")
-	if strings.Contains(dataSetPath, "..") {
-		return fmt.Errorf("invalid path")
+	columns, err := pq.columns(rows)
+	if err != nil {
+		page.close()
+		return nil, nil, fmt.Errorf("error getting columns: %w", err)
 	}
 
-	dbPath := filepath.Join(e.config.ServeFolder, dataSetPath)
+	page.res = &QueryResult{
+		Columns:     columns,
+		ColumnTypes: pq.columnTypes(rows, columns),
+		TotalCount:  totalCount,
+		CountType:   countType,
+		SQL:         query,
+		Links:       resultLinks(links, columns),
+	}
+	if rowidCol != "" && containsString(columns, rowidCol) {
+		page.res.RowIDColumn = rowidCol
+	}
+	return page, nil, nil
+}
 
-	db, err := e.getDBConnection(ctx, dbPath)
-	if err != nil {
-		return fmt.Errorf("error opening DB: %w", err)
+// close closes the rows of a page query and its connection.
+func (p *pageQuery) close() {
+	p.rows.Close()
+	p.pq.close()
+}
+
+// finish records a page query once its rows are read, caching entry unless it
+// is nil.
+func (p *pageQuery) finish(e *Engine, entry *cachedResult) {
+	fmt.Printf("[Engine.Query] TOTAL DURATION: %v\n", time.Since(p.start))
+	e.observeQuery(p.pq, time.Since(p.start))
+	if p.cacheKey != "" && entry != nil {
+		e.results.put(p.cacheKey, p.version, entry)
 	}
+}
 
-	// Optimizations handled in DSN
+// copyValues copies the rows of a result so that the cache and the results it
+// hands out never share them; the values themselves are never modified.
+func copyValues(values [][]interface{}) [][]interface{} {
+	out := make([][]interface{}, len(values))
+	for i, row := range values {
+		out[i] = make([]interface{}, len(row))
+		copy(out[i], row)
+	}
+	return out
+}
 
-	// Handle case where table name is missing
-	if bq.Table == "" {
-		rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type IN ('table', 'view') ORDER BY name")
-		if err != nil {
-			return fmt.Errorf("failed to list tables: %w", err)
-		}
-		var tables []string
-		for rows.Next() {
-			var name string
-			if err := rows.Scan(&name); err == nil {
-				tables = append(tables, name)
-			}
-		}
-		rows.Close()
+// scanBuffers returns the buffers to scan the rows of a page query into.
+func (pq *preparedQuery) scanBuffers(columns []string) ([]interface{}, []interface{}) {
+	if pq.withRowID {
+		return scanBuffers(len(columns) + 1)
+	}
+	return scanBuffers(len(columns))
+}
+
+// row copies a scanned row of a page query. The rowid selected after the
+// columns is dropped, and addresses the row's image cells instead.
+func (pq *preparedQuery) row(values []interface{}, columns []string, typed bool) []interface{} {
+	if !pq.withRowID {
+		return copyRow(values, typed)
+	}
+	rowid := values[len(columns)]
+	return typedRow(values[:len(columns)], func(i int) string { return pq.blobURL(columns[i], rowid) })
+}
+
+// scanBuffers returns a row buffer and the pointers to scan into it.
+func scanBuffers(n int) ([]interface{}, []interface{}) {
+	values := make([]interface{}, n)
+	ptrs := make([]interface{}, n)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	return values, ptrs
+}
 
-		if len(tables) == 1 {
-			bq.Table = tables[0]
-		} else if len(tables) == 0 {
-			return fmt.Errorf("no tables found in database")
+// copyRow copies a scanned row out of its buffer, converting byte slices to
+// strings, or every value to a TypedValue when typed.
+func copyRow(values []interface{}, typed bool) []interface{} {
+	row := make([]interface{}, len(values))
+	for i, val := range values {
+		if typed {
+			row[i] = typedValue(val)
+		} else if b, ok := val.([]byte); ok {
+			row[i] = string(b)
 		} else {
-			return fmt.Errorf("table name required. Available tables: %s", strings.Join(tables, ", "))
+			row[i] = val
 		}
 	}
+	return row
+}
+
+type QueryResultChunk struct {
+	Columns     []string        `json:"columns,omitempty"`
+	ColumnTypes []string        `json:"columnTypes,omitempty"`
+	Values      [][]interface{} `json:"values"`
+	TotalCount  int             `json:"totalCount,omitempty"`
+	CountType   string          `json:"countType,omitempty"`
+	SQL         string          `json:"sql,omitempty"`
+	Links       []ColumnLink    `json:"links,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// QueryStream executes a query and calls key callbacks during execution. An
+// error from onChunk stops the query, and is returned.
+func (e *Engine) QueryStream(ctx context.Context, opts QueryOptions, onChunk func(QueryResultChunk) error) error {
+	start := time.Now()
+	h := HistoryEntry{Kind: "stream", BanquetPath: opts.BanquetPath}
+	err := e.queryStream(ctx, opts, func(chunk QueryResultChunk) error {
+		if chunk.SQL != "" {
+			h.SQL = chunk.SQL
+		}
+		h.RowCount += int64(len(chunk.Values))
+		return onChunk(chunk)
+	})
+	e.recordHistory(h, opts, start, err)
+	return err
+}
+
+func (e *Engine) queryStream(ctx context.Context, opts QueryOptions, onChunk func(QueryResultChunk) error) error {
+	start := time.Now()
 
-	query := sqlite.Compose(bq)
+	// --- 1. Query Preparation (Identical to Query) ---
+	pq, err := e.prepareQuery(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer pq.close()
+	db := pq.db
+	query := pq.query
 
 	// --- 2. Get Total Count (Optional) ---
-	var totalCount int = -1
+	countStart := time.Now()
+	totalCount, countType := e.totalCount(ctx, pq, opts)
 	if !opts.SkipTotalCount {
-		countStart := time.Now()
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s", sqlite.QuoteIdentifier(bq.Table))
-		if bq.Where != "" {
-			countQuery += " WHERE " + bq.Where
-		}
-		_ = db.QueryRowContext(ctx, countQuery).Scan(&totalCount)
 		fmt.Printf("[Engine.QueryStream] TotalCount took %v\n", time.Since(countStart))
 	}
 
+	// Read before the main query occupies the database's single connection
+	links := e.tableLinks(ctx, db, pq.dbRelPath, pq.bq.Table)
+
 	// --- 3. Execute Main Query ---
 	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
@@ -441,28 +779,30 @@ func (e *Engine) QueryStream(ctx context.Context, opts QueryOptions, onChunk fun
 	}
 	defer rows.Close()
 
-	columns, err := rows.Columns()
+	columns, err := pq.columns(rows)
 	if err != nil {
 		return fmt.Errorf("error getting columns: %w", err)
 	}
 
 	// --- 4. Send Initial Metadata Chunk ---
-	onChunk(QueryResultChunk{
-		Columns:    columns,
-		TotalCount: totalCount,
-		SQL:        query,
-		Values:     [][]interface{}{}, // Empty values for first chunk
+	err = onChunk(QueryResultChunk{
+		Columns:     columns,
+		ColumnTypes: pq.columnTypes(rows, columns),
+		TotalCount:  totalCount,
+		CountType:   countType,
+		SQL:         query,
+		Links:       resultLinks(links, columns),
+		Values:      [][]interface{}{}, // Empty values for first chunk
 	})
+	if err != nil {
+		return err
+	}
 
 	// --- 5. Stream Rows ---
 	batchSize := 1000
 	buffer := make([][]interface{}, 0, batchSize)
 
-	values := make([]interface{}, len(columns))
-	valuePtrs := make([]interface{}, len(columns))
-	for i := range values {
-		valuePtrs[i] = &values[i]
-	}
+	values, valuePtrs := pq.scanBuffers(columns)
 
 	rowCount := 0
 	lastEmit := time.Now()
@@ -472,23 +812,14 @@ func (e *Engine) QueryStream(ctx context.Context, opts QueryOptions, onChunk fun
 			continue
 		}
 
-		rowData := make([]interface{}, len(columns))
-		for i := range columns {
-			val := values[i]
-			if b, ok := val.([]byte); ok {
-				rowData[i] = string(b)
-			} else {
-				rowData[i] = val
-			}
-		}
-		buffer = append(buffer, rowData)
+		buffer = append(buffer, pq.row(values, columns, opts.TypedValues))
 		rowCount++
 
 		// Emit chunk if full or if time has passed (streaming responsiveness)
 		if len(buffer) >= batchSize || time.Since(lastEmit) > 100*time.Millisecond {
-			onChunk(QueryResultChunk{
-				Values: buffer,
-			})
+			if err := onChunk(QueryResultChunk{Values: buffer}); err != nil {
+				return err
+			}
 			buffer = make([][]interface{}, 0, batchSize) // Alloc new slice, let GC handle old one
 			lastEmit = time.Now()
 		}
@@ -496,11 +827,12 @@ func (e *Engine) QueryStream(ctx context.Context, opts QueryOptions, onChunk fun
 
 	// Flush remaining
 	if len(buffer) > 0 {
-		onChunk(QueryResultChunk{
-			Values: buffer,
-		})
+		if err := onChunk(QueryResultChunk{Values: buffer}); err != nil {
+			return err
+		}
 	}
 
 	fmt.Printf("[Engine.QueryStream] Finished. %d rows in %v\n", rowCount, time.Since(start))
+	e.observeQuery(pq, time.Since(start))
 	return nil
 }
@@ -0,0 +1,249 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// GraphColumn is one column of a table in a SchemaGraph.
+type GraphColumn struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	NotNull    bool    `json:"notNull"`
+	Default    *string `json:"default,omitempty"`
+	PrimaryKey int     `json:"primaryKey,omitempty"` // 1-based position in the primary key, 0 if not part of it
+}
+
+// GraphTable is a table or view in a SchemaGraph.
+type GraphTable struct {
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	Columns []GraphColumn `json:"columns"`
+}
+
+// GraphEdge is a reference from the columns of one table to those of another.
+type GraphEdge struct {
+	From        string   `json:"from"`
+	FromColumns []string `json:"fromColumns"`
+	To          string   `json:"to"`
+	ToColumns   []string `json:"toColumns"`
+	Inferred    bool     `json:"inferred,omitempty"` // Guessed from a *_id column name rather than declared
+}
+
+// SchemaGraph is the entity-relationship graph of a database.
+type SchemaGraph struct {
+	DB     string       `json:"db"`
+	Tables []GraphTable `json:"tables"`
+	Edges  []GraphEdge  `json:"edges"`
+}
+
+// SchemaGraphOptions controls how a SchemaGraph is built.
+type SchemaGraphOptions struct {
+	// InferEdges adds edges for columns named <table>_id (or <singular>_id) that
+	// are not covered by a declared foreign key, pointing at that table's primary key.
+	InferEdges bool
+}
+
+// tableColumnInfo returns the columns of a table or view from pragma_table_info.
+func tableColumnInfo(ctx context.Context, db *sql.DB, table string) ([]GraphColumn, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make([]GraphColumn, 0)
+	for rows.Next() {
+		var c GraphColumn
+		var def sql.NullString
+		if err := rows.Scan(&c.Name, &c.Type, &c.NotNull, &def, &c.PrimaryKey); err != nil {
+			continue
+		}
+		if def.Valid {
+			c.Default = &def.String
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+// SchemaGraph builds the entity-relationship graph of a database from its
+// table, column and foreign-key pragmas. FTS shadow tables are left out.
+func (e *Engine) SchemaGraph(ctx context.Context, dbRelPath string, opts SchemaGraphOptions) (*SchemaGraph, error) {
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := e.ListTables(ctx, dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	virtual, err := virtualTables(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	graph := &SchemaGraph{DB: dbRelPath, Tables: make([]GraphTable, 0), Edges: make([]GraphEdge, 0)}
+	declared := make(map[string]bool) // "table.column" covered by a declared foreign key
+	for _, t := range tables {
+		if isInternalTable(t.Name, virtual) {
+			continue
+		}
+		cols, err := tableColumnInfo(ctx, db, t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		graph.Tables = append(graph.Tables, GraphTable{Name: t.Name, Type: t.Type, Columns: cols})
+		if t.Type != "table" {
+			continue
+		}
+
+		fks, err := tableForeignKeys(ctx, db, t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		for _, fk := range fks {
+			to, err := referencedColumns(ctx, db, fk)
+			if err != nil {
+				return nil, fmt.Errorf("database error: %w", err)
+			}
+			graph.Edges = append(graph.Edges, GraphEdge{From: t.Name, FromColumns: fk.From, To: fk.Ref, ToColumns: to})
+			for _, c := range fk.From {
+				declared[strings.ToLower(t.Name+"."+c)] = true
+			}
+		}
+	}
+
+	if opts.InferEdges {
+		graph.Edges = append(graph.Edges, inferEdges(graph.Tables, declared)...)
+	}
+	return graph, nil
+}
+
+// inferEdges guesses references from columns named <table>_id. The referenced
+// table is matched case-insensitively by name, plural "s" or "es", and must
+// have a single-column primary key.
+func inferEdges(tables []GraphTable, declared map[string]bool) []GraphEdge {
+	byName := make(map[string]GraphTable)
+	for _, t := range tables {
+		if t.Type == "table" {
+			byName[strings.ToLower(t.Name)] = t
+		}
+	}
+
+	var edges []GraphEdge
+	for _, t := range tables {
+		if t.Type != "table" {
+			continue
+		}
+		for _, c := range t.Columns {
+			lower := strings.ToLower(c.Name)
+			if !strings.HasSuffix(lower, "_id") || declared[strings.ToLower(t.Name+"."+c.Name)] {
+				continue
+			}
+			stem := strings.TrimSuffix(lower, "_id")
+			for _, candidate := range []string{stem, stem + "s", stem + "es"} {
+				target, ok := byName[candidate]
+				if !ok || strings.EqualFold(target.Name, t.Name) {
+					continue
+				}
+				var pk []string
+				for _, tc := range target.Columns {
+					if tc.PrimaryKey > 0 {
+						pk = append(pk, tc.Name)
+					}
+				}
+				if len(pk) != 1 {
+					continue
+				}
+				edges = append(edges, GraphEdge{From: t.Name, FromColumns: []string{c.Name}, To: target.Name, ToColumns: pk, Inferred: true})
+				break
+			}
+		}
+	}
+	return edges
+}
+
+// DOT renders the graph for Graphviz, one HTML-table node per table.
+func (g *SchemaGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=plaintext];\n")
+	for _, t := range g.Tables {
+		fmt.Fprintf(&b, "\t%s [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\">", dotID(t.Name))
+		title := html.EscapeString(t.Name)
+		if t.Type == "view" {
+			title = "<i>" + title + "</i>"
+		}
+		fmt.Fprintf(&b, "<tr><td bgcolor=\"lightgrey\"><b>%s</b></td></tr>", title)
+		for _, c := range t.Columns {
+			name := html.EscapeString(c.Name)
+			if c.PrimaryKey > 0 {
+				name = "<u>" + name + "</u>"
+			}
+			fmt.Fprintf(&b, "<tr><td align=\"left\">%s %s</td></tr>", name, html.EscapeString(c.Type))
+		}
+		b.WriteString("</table>>];\n")
+	}
+	for _, e := range g.Edges {
+		attrs := fmt.Sprintf("label=%s", dotID(strings.Join(e.FromColumns, ", ")))
+		if e.Inferred {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", dotID(e.From), dotID(e.To), attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotID quotes a string as a Graphviz ID.
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+var mermaidUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// mermaidName reduces a name to the characters Mermaid accepts in entity names and types.
+func mermaidName(s string) string {
+	s = mermaidUnsafe.ReplaceAllString(s, "_")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// Mermaid renders the graph as a Mermaid erDiagram. Inferred edges are drawn
+// as non-identifying (dotted) relationships.
+func (g *SchemaGraph) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, t := range g.Tables {
+		fmt.Fprintf(&b, "    %s {\n", mermaidName(t.Name))
+		for _, c := range t.Columns {
+			typ := c.Type
+			if typ == "" {
+				typ = "ANY"
+			}
+			fmt.Fprintf(&b, "        %s %s", mermaidName(typ), mermaidName(c.Name))
+			if c.PrimaryKey > 0 {
+				b.WriteString(" PK")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, e := range g.Edges {
+		line := "--"
+		if e.Inferred {
+			line = ".."
+		}
+		label := strings.ReplaceAll(strings.Join(e.FromColumns, ", "), `"`, "'")
+		fmt.Fprintf(&b, "    %s }o%s|| %s : \"%s\"\n", mermaidName(e.From), line, mermaidName(e.To), label)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package sqliter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSchemaGraph(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers, total REAL);
+		CREATE TABLE items (order_id INTEGER, product_id INTEGER, sku TEXT);
+		CREATE VIEW big_orders AS SELECT * FROM orders WHERE total > 100;
+		CREATE VIRTUAL TABLE items_fts USING fts5(sku);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	graph, err := engine.SchemaGraph(ctx, "shop.db", SchemaGraphOptions{})
+	if err != nil {
+		t.Fatalf("SchemaGraph failed: %v", err)
+	}
+	if len(graph.Tables) != 4 {
+		t.Fatalf("Expected 3 tables and 1 view without FTS tables, got %+v", graph.Tables)
+	}
+	if len(graph.Edges) != 1 || graph.Edges[0].From != "orders" || graph.Edges[0].To != "customers" || graph.Edges[0].ToColumns[0] != "id" {
+		t.Fatalf("Expected the declared orders -> customers edge, got %+v", graph.Edges)
+	}
+
+	t.Run("Inferred", func(t *testing.T) {
+		graph, err := engine.SchemaGraph(ctx, "shop.db", SchemaGraphOptions{InferEdges: true})
+		if err != nil {
+			t.Fatalf("SchemaGraph failed: %v", err)
+		}
+		// items.order_id matches "orders"; product_id has no table; customer_id is declared
+		if len(graph.Edges) != 2 || !graph.Edges[1].Inferred || graph.Edges[1].From != "items" || graph.Edges[1].To != "orders" {
+			t.Fatalf("Expected one inferred items -> orders edge, got %+v", graph.Edges)
+		}
+		if !strings.Contains(graph.Mermaid(), "items }o..|| orders") {
+			t.Errorf("Expected a dotted Mermaid relationship, got:\n%s", graph.Mermaid())
+		}
+	})
+
+	t.Run("Formats", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		for format, want := range map[string]string{
+			"dot":     `"orders" -> "customers" [label="customer_id"];`,
+			"mermaid": "orders }o--|| customers",
+			"json":    `"from":"orders"`,
+		} {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/erd?db=shop.db&format="+format, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected status 200, got %d: %s", format, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("%s: expected %q in:\n%s", format, want, w.Body.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package sqliter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/darianmavgo/banquet"
+)
+
+// apiCacheControl lets clients and proxies store responses but revalidate them
+// on every use, since a database can change at any time.
+const apiCacheControl = "no-cache"
+
+// notModified sets the ETag and Cache-Control headers of a response derived
+// from a database, and answers 304 Not Modified when the request's If-None-Match
+// already holds that ETag. The ETag covers the database's identity and version,
+// the request parameters and the settings that shape the response. It returns
+// false, leaving the response uncached, when the database version is unknown.
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request, dbRelPath string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	version, err := s.engine.DatabaseVersion(r.Context(), dbRelPath)
+	if err != nil {
+		return false
+	}
+
+	h := sha256.New()
+	for _, part := range []string{
+		r.URL.Path,
+		strings.TrimPrefix(dbRelPath, "/"),
+		version,
+		r.URL.Query().Encode(), // Sorted by key
+		r.Header.Get("Accept"), // Selects the output format
+		strconv.FormatBool(s.config.AutoRedirectSingleTable),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	// Weak: the same rows may be encoded differently
+	etag := `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", apiCacheControl)
+	w.Header().Add("Vary", "Accept")
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// etagWriter holds back a response body so that its ETag is only sent with a
+// complete response. Once the body outgrows jsonFlushSize it is passed on as it
+// is written, without the ETag, since an error could still cut it short.
+type etagWriter struct {
+	w         http.ResponseWriter
+	buf       []byte
+	streaming bool
+}
+
+func (e *etagWriter) Write(p []byte) (int, error) {
+	if !e.streaming && len(e.buf)+len(p) <= jsonFlushSize {
+		e.buf = append(e.buf, p...)
+		return len(p), nil
+	}
+	if !e.streaming {
+		e.streaming = true
+		e.w.Header().Del("ETag")
+		if len(e.buf) > 0 {
+			if _, err := e.w.Write(e.buf); err != nil {
+				return 0, err
+			}
+			e.buf = nil
+		}
+	}
+	return e.w.Write(p)
+}
+
+// finish writes a body that was held back in full, together with its ETag.
+func (e *etagWriter) finish() error {
+	if e.streaming {
+		return nil
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// cellETag identifies a version of one cell. It is strong, as cells are served
+// byte for byte, so If-Range can resume their downloads.
+func cellETag(path, column string, rowid int64, version string) string {
+	h := sha256.New()
+	for _, part := range []string{path, column, strconv.FormatInt(rowid, 10), version} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatch reports whether an If-None-Match header lists etag, using the weak
+// comparison RFC 9110 requires for If-None-Match.
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// queryDatabase returns the database a rows request reads, or "" when its
+// response depends on other databases too.
+func queryDatabase(opts QueryOptions) string {
+	if len(opts.Attach) > 0 {
+		return ""
+	}
+	bq, err := banquet.ParseNested(opts.BanquetPath)
+	if err != nil {
+		return ""
+	}
+	return bq.DataSetPath
+}
@@ -0,0 +1,107 @@
+package sqliter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestConditionalGet(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "archive.db")
+	createTestDB(t, dbPath, `
+		CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO items (name) VALUES ('a'), ('b');
+	`)
+
+	srv := NewServer(&Config{ServeFolder: tmpDir})
+	defer srv.engine.CloseAll()
+
+	get := func(t *testing.T, url, ifNoneMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, url := range []string{"/sqliter/tables?db=archive.db", "/sqliter/rows?path=/archive.db/items"} {
+		t.Run(url, func(t *testing.T) {
+			w := get(t, url, "")
+			etag := w.Header().Get("ETag")
+			if w.Code != http.StatusOK || etag == "" {
+				t.Fatalf("Expected 200 with an ETag, got %d %q", w.Code, etag)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != apiCacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", apiCacheControl, cc)
+			}
+
+			w = get(t, url, `"other", `+etag)
+			if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Errorf("Expected an empty 304, got %d: %s", w.Code, w.Body.String())
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("Expected the 304 to repeat the ETag")
+			}
+
+			if w := get(t, url+"&x=1", etag); w.Code != http.StatusOK {
+				t.Errorf("Expected other parameters to change the ETag, got %d", w.Code)
+			}
+
+			createTestDB(t, dbPath, "INSERT INTO items (name) VALUES ('c')")
+			w = get(t, url, etag)
+			if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+				t.Errorf("Expected a new ETag after a write, got %d %q", w.Code, w.Header().Get("ETag"))
+			}
+		})
+	}
+
+	t.Run("Large JSON pages", func(t *testing.T) {
+		createTestDB(t, dbPath, `
+			CREATE TABLE wide (body TEXT);
+			WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 200)
+			INSERT INTO wide SELECT printf('%.500c', 'x') FROM c;
+		`)
+		w := get(t, "/sqliter/rows?path=/archive.db/wide", "")
+		if w.Code != http.StatusOK || w.Body.Len() <= jsonFlushSize {
+			t.Fatalf("Expected a body over %d bytes, got %d with %d", jsonFlushSize, w.Code, w.Body.Len())
+		}
+		if w.Header().Get("ETag") != "" {
+			t.Errorf("Expected a streamed page to be sent without an ETag")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		w := get(t, "/sqliter/rows?path=/archive.db/missing", "")
+		if w.Code == http.StatusOK || w.Header().Get("ETag") != "" {
+			t.Errorf("Expected an error without an ETag, got %d %q", w.Code, w.Header().Get("ETag"))
+		}
+		w = get(t, "/sqliter/tables?db=nope.db", "")
+		if w.Header().Get("ETag") != "" {
+			t.Errorf("Expected no ETag for a missing database")
+		}
+	})
+}
+
+func TestETagMatch(t *testing.T) {
+	etag := `W/"abc"`
+	for header, want := range map[string]bool{
+		``:             false,
+		`*`:            true,
+		`"abc"`:        true,
+		`W/"abc"`:      true,
+		`"x", W/"abc"`: true,
+		`"abcd", "ab"`: false,
+		`W/"xyz"`:      false,
+	} {
+		if got := etagMatch(header, etag); got != want {
+			t.Errorf("etagMatch(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
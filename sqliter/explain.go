@@ -0,0 +1,121 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// PlanNode is one step of an EXPLAIN QUERY PLAN tree.
+type PlanNode struct {
+	ID       int         `json:"id"`
+	Detail   string      `json:"detail"`
+	Children []*PlanNode `json:"children,omitempty"`
+}
+
+// PlanFlag marks a plan step that is a likely cause of a slow query.
+type PlanFlag struct {
+	Kind   string `json:"kind"`            // full_scan, temp_btree or automatic_index
+	Table  string `json:"table,omitempty"` // Table scanned or indexed, when known
+	Detail string `json:"detail"`
+}
+
+// QueryPlan is the plan of one statement.
+type QueryPlan struct {
+	SQL   string      `json:"sql"`
+	Plan  []*PlanNode `json:"plan"`
+	Flags []PlanFlag  `json:"flags"`
+}
+
+// ExplainResult holds the plans of the statements Engine.Query would run.
+type ExplainResult struct {
+	Query QueryPlan  `json:"query"`
+	Count *QueryPlan `json:"count,omitempty"` // Nil when the total count is skipped
+}
+
+// Explain runs EXPLAIN QUERY PLAN on the page and count queries that Query
+// would execute for opts, without running them.
+func (e *Engine) Explain(ctx context.Context, opts QueryOptions) (*ExplainResult, error) {
+	pq, err := e.prepareQuery(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer pq.close()
+
+	plan, err := explainQuery(ctx, pq.db, pq.query)
+	if err != nil {
+		return nil, err
+	}
+	result := &ExplainResult{Query: *plan}
+	if !opts.SkipTotalCount {
+		if result.Count, err = explainQuery(ctx, pq.db, pq.countQuery); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// explainQuery returns the plan tree of a statement and flags its costly steps.
+func explainQuery(ctx context.Context, db *sql.DB, query string) (*QueryPlan, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query)
+	if err != nil {
+		return nil, fmt.Errorf("explain error: %w", err)
+	}
+	defer rows.Close()
+
+	plan := &QueryPlan{SQL: query, Plan: make([]*PlanNode, 0), Flags: make([]PlanFlag, 0)}
+	nodes := make(map[int]*PlanNode)
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			return nil, fmt.Errorf("explain error: %w", err)
+		}
+		node := &PlanNode{ID: id, Detail: detail}
+		nodes[id] = node
+		if p, ok := nodes[parent]; ok {
+			p.Children = append(p.Children, node)
+		} else {
+			plan.Plan = append(plan.Plan, node)
+		}
+		if flag, ok := flagPlanStep(detail); ok {
+			plan.Flags = append(plan.Flags, flag)
+		}
+	}
+	return plan, rows.Err()
+}
+
+// flagPlanStep recognizes full table scans, temp B-trees and automatic indexes.
+// SQLite before 3.36 wrote "SCAN TABLE t" and "SEARCH TABLE t"; later versions drop TABLE.
+func flagPlanStep(detail string) (PlanFlag, bool) {
+	switch {
+	case strings.Contains(detail, "AUTOMATIC"):
+		return PlanFlag{Kind: "automatic_index", Table: planTable(detail), Detail: detail}, true
+	case strings.HasPrefix(detail, "USE TEMP B-TREE"):
+		return PlanFlag{Kind: "temp_btree", Detail: detail}, true
+	case strings.HasPrefix(detail, "SCAN ") && !strings.Contains(detail, " USING ") && !strings.Contains(detail, "VIRTUAL TABLE"):
+		table := planTable(detail)
+		if table == "" || strings.HasPrefix(table, "(") || strings.HasPrefix(detail, "SCAN CONSTANT") {
+			return PlanFlag{}, false // Subquery results and constant rows are not tables
+		}
+		return PlanFlag{Kind: "full_scan", Table: table, Detail: detail}, true
+	}
+	return PlanFlag{}, false
+}
+
+// planTable extracts the table name from a SCAN or SEARCH step.
+func planTable(detail string) string {
+	fields := strings.Fields(detail)
+	if len(fields) < 2 {
+		return ""
+	}
+	name := fields[1]
+	if name == "TABLE" && len(fields) > 2 {
+		name = fields[2]
+	}
+	if name == "SUBQUERY" || name == "CONSTANT" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,81 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestExplain(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL);
+		CREATE INDEX orders_customer ON orders(customer);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	hasFlag := func(p *QueryPlan, kind string) bool {
+		for _, f := range p.Flags {
+			if f.Kind == kind {
+				return true
+			}
+		}
+		return false
+	}
+
+	res, err := engine.Explain(ctx, QueryOptions{BanquetPath: "/shop.db/orders", FilterWhere: "total > 10", SortCol: "total"})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if len(res.Query.Plan) == 0 || res.Count == nil {
+		t.Fatalf("Expected plans for the page and count queries, got %+v", res)
+	}
+	if !hasFlag(&res.Query, "full_scan") || res.Query.Flags[0].Table != "orders" {
+		t.Errorf("Expected a full scan of orders, got %+v", res.Query.Flags)
+	}
+	if !hasFlag(&res.Query, "temp_btree") {
+		t.Errorf("Expected a temp B-tree for ORDER BY total, got %+v", res.Query.Flags)
+	}
+
+	res, err = engine.Explain(ctx, QueryOptions{BanquetPath: "/shop.db/orders", FilterWhere: "customer = 'ada'", SkipTotalCount: true})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if len(res.Query.Flags) != 0 || res.Count != nil {
+		t.Errorf("Expected an indexed search without flags or count plan, got %+v", res)
+	}
+
+	t.Run("Tree", func(t *testing.T) {
+		res, err := engine.Explain(ctx, QueryOptions{BanquetPath: "/shop.db/orders", FilterWhere: "id IN (SELECT id FROM orders WHERE total > 1)"})
+		if err != nil {
+			t.Fatalf("Explain failed: %v", err)
+		}
+		nested := false
+		for _, n := range res.Query.Plan {
+			if len(n.Children) > 0 {
+				nested = true
+			}
+		}
+		if !nested {
+			t.Errorf("Expected the subquery nested in the plan tree, got %+v", res.Query.Plan)
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/explain?path=/shop.db/orders&sortCol=total", nil))
+		var body ExplainResult
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&body) != nil || body.Query.SQL == "" {
+			t.Errorf("Unexpected response %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,427 @@
+package sqliter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Output formats of the rows and stream APIs.
+const (
+	FormatJSON    = "json"
+	FormatArrow   = "arrow"   // Apache Arrow IPC stream
+	FormatMsgpack = "msgpack" // MessagePack, with the JSON field names
+)
+
+var formatContentTypes = map[string]string{
+	FormatJSON:    "application/json",
+	FormatArrow:   "application/vnd.apache.arrow.stream",
+	FormatMsgpack: "application/msgpack",
+}
+
+// negotiateFormat picks the output format from the format parameter, or else
+// from the Accept header, defaulting to JSON.
+func negotiateFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if _, ok := formatContentTypes[format]; !ok {
+			return "", fmt.Errorf("format must be json, arrow or msgpack")
+		}
+		return format, nil
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/vnd.apache.arrow.stream":
+			return FormatArrow, nil
+		case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+			return FormatMsgpack, nil
+		case "application/json":
+			return FormatJSON, nil
+		}
+	}
+	return FormatJSON, nil
+}
+
+// resultColumnTypes returns the declared SQLite types of a result's columns.
+func resultColumnTypes(rows *sql.Rows) []string {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil
+	}
+	decl := make([]string, len(types))
+	for i, t := range types {
+		decl[i] = t.DatabaseTypeName()
+	}
+	return decl
+}
+
+// newMsgpackEncoder encodes results with the same field names as JSON.
+func newMsgpackEncoder(w io.Writer) *msgpack.Encoder {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc
+}
+
+// writeResult writes a query result in the given format.
+func writeResult(w io.Writer, format string, res *QueryResult) error {
+	switch format {
+	case FormatArrow:
+		types := make([]arrow.DataType, len(res.Columns))
+		for i := range types {
+			types[i] = arrowType(declType(res.ColumnTypes, i), columnKinds(res.Values, i))
+		}
+		schema := arrowSchema(res.Columns, res.ColumnTypes, types, map[string]string{
+			"sql":        res.SQL,
+			"totalCount": strconv.Itoa(res.TotalCount),
+			"countType":  res.CountType,
+		})
+		aw := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(memory.DefaultAllocator))
+		if err := writeArrowBatch(aw, schema, res.Values); err != nil {
+			aw.Close()
+			return err
+		}
+		return aw.Close()
+	case FormatMsgpack:
+		return newMsgpackEncoder(w).Encode(res)
+	default:
+		return json.NewEncoder(w).Encode(res)
+	}
+}
+
+// chunkEncoder writes the chunks of a streamed query in one format.
+type chunkEncoder interface {
+	WriteChunk(chunk QueryResultChunk) error
+	Close() error
+}
+
+func newChunkEncoder(w io.Writer, format string) chunkEncoder {
+	switch format {
+	case FormatArrow:
+		return &arrowChunkEncoder{w: w}
+	case FormatMsgpack:
+		return &msgpackChunkEncoder{enc: newMsgpackEncoder(w)}
+	default:
+		return &jsonChunkEncoder{enc: json.NewEncoder(w)}
+	}
+}
+
+// jsonChunkEncoder writes one JSON chunk per line.
+type jsonChunkEncoder struct{ enc *json.Encoder }
+
+func (e *jsonChunkEncoder) WriteChunk(chunk QueryResultChunk) error { return e.enc.Encode(chunk) }
+func (e *jsonChunkEncoder) Close() error                            { return nil }
+
+// msgpackChunkEncoder writes a sequence of MessagePack chunk maps.
+type msgpackChunkEncoder struct{ enc *msgpack.Encoder }
+
+func (e *msgpackChunkEncoder) WriteChunk(chunk QueryResultChunk) error { return e.enc.Encode(chunk) }
+func (e *msgpackChunkEncoder) Close() error                            { return nil }
+
+// arrowChunkEncoder writes one record batch per chunk. Later chunks cannot
+// change the schema, so it is derived from declared types alone (see
+// streamArrowType) and values that do not fit it fail the stream.
+type arrowChunkEncoder struct {
+	w        io.Writer
+	meta     QueryResultChunk
+	schema   *arrow.Schema
+	writer   *ipc.Writer
+	received bool
+}
+
+func (e *arrowChunkEncoder) WriteChunk(chunk QueryResultChunk) error {
+	if chunk.Error != "" {
+		return fmt.Errorf("%s", chunk.Error)
+	}
+	if !e.received {
+		e.meta, e.received = chunk, true
+		if len(chunk.Values) == 0 {
+			return nil
+		}
+	}
+	e.start()
+	return writeArrowBatch(e.writer, e.schema, chunk.Values)
+}
+
+func (e *arrowChunkEncoder) start() {
+	if e.writer != nil {
+		return
+	}
+	types := make([]arrow.DataType, len(e.meta.Columns))
+	for i := range types {
+		types[i] = streamArrowType(declType(e.meta.ColumnTypes, i))
+	}
+	e.schema = arrowSchema(e.meta.Columns, e.meta.ColumnTypes, types, map[string]string{
+		"sql":        e.meta.SQL,
+		"totalCount": strconv.Itoa(e.meta.TotalCount),
+		"countType":  e.meta.CountType,
+	})
+	e.writer = ipc.NewWriter(e.w, ipc.WithSchema(e.schema), ipc.WithAllocator(memory.DefaultAllocator))
+}
+
+// Close ends the stream, writing the schema alone if no rows were received.
+func (e *arrowChunkEncoder) Close() error {
+	if !e.received {
+		return nil
+	}
+	e.start()
+	return e.writer.Close()
+}
+
+// SQLite type affinities, as derived from declared types.
+const (
+	affinityInteger = "INTEGER"
+	affinityText    = "TEXT"
+	affinityBlob    = "BLOB"
+	affinityReal    = "REAL"
+	affinityNumeric = "NUMERIC"
+)
+
+// typeAffinity applies SQLite's rules for determining column affinity
+// (https://www.sqlite.org/datatype3.html#determination_of_column_affinity).
+// Columns without a declared type, such as expressions, get "".
+func typeAffinity(decl string) string {
+	decl = strings.ToUpper(decl)
+	switch {
+	case decl == "":
+		return ""
+	case strings.Contains(decl, "INT"):
+		return affinityInteger
+	case strings.Contains(decl, "CHAR"), strings.Contains(decl, "CLOB"), strings.Contains(decl, "TEXT"):
+		return affinityText
+	case strings.Contains(decl, "BLOB"):
+		return affinityBlob
+	case strings.Contains(decl, "REAL"), strings.Contains(decl, "FLOA"), strings.Contains(decl, "DOUB"):
+		return affinityReal
+	}
+	return affinityNumeric
+}
+
+// Go value kinds found in a column, as a bit set.
+const (
+	kindInt = 1 << iota
+	kindFloat
+	kindString
+	kindBytes
+	kindBool
+	kindTime
+	kindOther
+)
+
+func columnKinds(values [][]interface{}, col int) int {
+	kinds := 0
+	for _, row := range values {
+		switch row[col].(type) {
+		case nil:
+		case int64:
+			kinds |= kindInt
+		case float64:
+			kinds |= kindFloat
+		case string:
+			kinds |= kindString
+		case []byte:
+			kinds |= kindBytes
+		case bool:
+			kinds |= kindBool
+		case time.Time:
+			kinds |= kindTime
+		default:
+			kinds |= kindOther
+		}
+	}
+	return kinds
+}
+
+var arrowTimestamp = &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+
+// arrowType derives a column's Arrow type from its declared type and checks it
+// against the column's values. SQLite does not enforce declared types, so a
+// column whose values do not fit is sent as strings rather than losing them.
+func arrowType(decl string, kinds int) arrow.DataType {
+	only := func(allowed int) bool { return kinds&^allowed == 0 }
+	affinity := typeAffinity(decl)
+	upper := strings.ToUpper(decl)
+
+	if kinds == 0 {
+		// No values to check: trust the declared type
+		switch affinity {
+		case affinityInteger:
+			return arrow.PrimitiveTypes.Int64
+		case affinityReal:
+			return arrow.PrimitiveTypes.Float64
+		case affinityBlob:
+			return arrow.BinaryTypes.Binary
+		case affinityNumeric:
+			if strings.Contains(upper, "DATE") || strings.Contains(upper, "TIME") {
+				return arrowTimestamp
+			}
+			return arrow.PrimitiveTypes.Float64
+		}
+		return arrow.BinaryTypes.String
+	}
+
+	switch affinity {
+	case affinityText:
+		return arrow.BinaryTypes.String
+	case affinityBlob:
+		if only(kindBytes | kindString) {
+			return arrow.BinaryTypes.Binary
+		}
+	case affinityInteger:
+		if only(kindInt) {
+			return arrow.PrimitiveTypes.Int64
+		}
+	case affinityReal:
+		if only(kindInt | kindFloat) {
+			return arrow.PrimitiveTypes.Float64
+		}
+	}
+
+	// Numeric affinity, no declared type, or values that do not fit the declared type
+	switch {
+	case only(kindInt):
+		return arrow.PrimitiveTypes.Int64
+	case only(kindInt | kindFloat):
+		return arrow.PrimitiveTypes.Float64
+	case only(kindBool):
+		return arrow.FixedWidthTypes.Boolean
+	case only(kindTime):
+		return arrowTimestamp
+	case only(kindBytes):
+		return arrow.BinaryTypes.Binary
+	}
+	return arrow.BinaryTypes.String
+}
+
+// streamArrowType derives a streamed column's Arrow type from its declared
+// type's affinity. Columns of NUMERIC affinity, or without a declared type, may
+// hold values of any type, so they are sent as strings.
+func streamArrowType(decl string) arrow.DataType {
+	switch typeAffinity(decl) {
+	case affinityInteger:
+		return arrow.PrimitiveTypes.Int64
+	case affinityReal:
+		return arrow.PrimitiveTypes.Float64
+	case affinityBlob:
+		return arrow.BinaryTypes.Binary
+	}
+	return arrow.BinaryTypes.String
+}
+
+// declType returns the declared type of column i, or "" if it has none.
+func declType(declTypes []string, i int) string {
+	if i < len(declTypes) {
+		return declTypes[i]
+	}
+	return ""
+}
+
+// arrowSchema builds the schema of a result; metadata carries the query details.
+func arrowSchema(columns, declTypes []string, types []arrow.DataType, meta map[string]string) *arrow.Schema {
+	fields := make([]arrow.Field, len(columns))
+	for i, name := range columns {
+		decl := declType(declTypes, i)
+		fields[i] = arrow.Field{Name: name, Type: types[i], Nullable: true}
+		if decl != "" {
+			md := arrow.NewMetadata([]string{"sqlite.type"}, []string{decl})
+			fields[i].Metadata = md
+		}
+	}
+	md := arrow.MetadataFrom(meta)
+	return arrow.NewSchema(fields, &md)
+}
+
+// writeArrowBatch writes rows as one record batch.
+func writeArrowBatch(w *ipc.Writer, schema *arrow.Schema, values [][]interface{}) error {
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	for _, row := range values {
+		for i, v := range row {
+			if err := appendArrowValue(b.Field(i), v); err != nil {
+				return fmt.Errorf("column %s: %w", schema.Field(i).Name, err)
+			}
+		}
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+	return w.Write(rec)
+}
+
+// appendArrowValue appends a value to a column builder, converting between
+// compatible types. Values that cannot be converted are an error.
+func appendArrowValue(b array.Builder, v interface{}) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	switch b := b.(type) {
+	case *array.Int64Builder:
+		switch v := v.(type) {
+		case int64:
+			b.Append(v)
+			return nil
+		case bool:
+			if v {
+				b.Append(1)
+			} else {
+				b.Append(0)
+			}
+			return nil
+		}
+	case *array.Float64Builder:
+		switch v := v.(type) {
+		case float64:
+			b.Append(v)
+			return nil
+		case int64:
+			b.Append(float64(v))
+			return nil
+		}
+	case *array.BooleanBuilder:
+		if v, ok := v.(bool); ok {
+			b.Append(v)
+			return nil
+		}
+	case *array.TimestampBuilder:
+		if v, ok := v.(time.Time); ok {
+			b.Append(arrow.Timestamp(v.UnixMicro()))
+			return nil
+		}
+	case *array.BinaryBuilder:
+		switch v := v.(type) {
+		case []byte:
+			b.Append(v)
+			return nil
+		case string:
+			b.AppendString(v)
+			return nil
+		}
+	case *array.StringBuilder:
+		switch v := v.(type) {
+		case string:
+			b.Append(v)
+		case []byte:
+			b.Append(string(v))
+		case time.Time:
+			b.Append(v.Format(time.RFC3339Nano))
+		default:
+			b.Append(fmt.Sprint(v))
+		}
+		return nil
+	}
+	return fmt.Errorf("%T value does not fit Arrow type %s", v, b.Type())
+}
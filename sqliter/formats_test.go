@@ -0,0 +1,282 @@
+package sqliter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/vmihailenco/msgpack/v5"
+	_ "modernc.org/sqlite"
+)
+
+func TestTypeAffinity(t *testing.T) {
+	for decl, want := range map[string]string{
+		"INTEGER":          affinityInteger,
+		"bigint":           affinityInteger,
+		"VARCHAR(10)":      affinityText,
+		"CLOB":             affinityText,
+		"BLOB":             affinityBlob,
+		"DOUBLE PRECISION": affinityReal,
+		"FLOAT":            affinityReal,
+		"DECIMAL(10,2)":    affinityNumeric,
+		"DATETIME":         affinityNumeric,
+		"CHARINT":          affinityInteger, // INT wins, as in SQLite
+		"":                 "",
+	} {
+		if got := typeAffinity(decl); got != want {
+			t.Errorf("typeAffinity(%q) = %q, want %q", decl, got, want)
+		}
+	}
+}
+
+func TestResultFormats(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL, qty INTEGER, data BLOB, added DATETIME);
+		INSERT INTO items (name, price, qty, data, added) VALUES
+			('apple', 1.5, 3, x'00ff', '2024-01-02 03:04:05'),
+			('pear', 2, 'n/a', NULL, NULL);
+		CREATE VIEW totals AS SELECT id, price * 2 AS doubled, 'x' || name AS label FROM items;
+		CREATE TABLE big (n INTEGER);
+		WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 2500)
+		INSERT INTO big SELECT i FROM c;
+		CREATE TABLE mixed (a, b NUMERIC);
+		WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 1500)
+		INSERT INTO mixed SELECT iif(i <= 1200, i, 'row ' || i), iif(i <= 1200, i, i + 0.5) FROM c;
+	`)
+
+	srv := NewServer(&Config{ServeFolder: tmpDir})
+	defer srv.engine.CloseAll()
+
+	get := func(t *testing.T, url, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	readArrow := func(t *testing.T, body []byte) (*arrow.Schema, []arrow.Record) {
+		t.Helper()
+		rdr, err := ipc.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to read Arrow stream: %v", err)
+		}
+		defer rdr.Release()
+		var recs []arrow.Record
+		for rdr.Next() {
+			rec := rdr.Record()
+			rec.Retain()
+			recs = append(recs, rec)
+		}
+		if err := rdr.Err(); err != nil {
+			t.Fatalf("Arrow stream error: %v", err)
+		}
+		return rdr.Schema(), recs
+	}
+
+	t.Run("Arrow", func(t *testing.T) {
+		w := get(t, "/sqliter/rows?path=/shop.db/items&format=arrow", "")
+		if ct := w.Header().Get("Content-Type"); ct != "application/vnd.apache.arrow.stream" {
+			t.Errorf("Unexpected Content-Type %q", ct)
+		}
+		schema, recs := readArrow(t, w.Body.Bytes())
+		want := map[string]arrow.DataType{
+			"id":    arrow.PrimitiveTypes.Int64,
+			"name":  arrow.BinaryTypes.String,
+			"price": arrow.PrimitiveTypes.Float64,
+			"qty":   arrow.BinaryTypes.String, // Declared INTEGER, but holds text
+			"data":  arrow.BinaryTypes.Binary,
+			"added": arrowTimestamp,
+		}
+		for _, f := range schema.Fields() {
+			if !arrow.TypeEqual(f.Type, want[f.Name]) {
+				t.Errorf("Column %s: expected %s, got %s", f.Name, want[f.Name], f.Type)
+			}
+		}
+		if v, _ := schema.Metadata().GetValue("totalCount"); v != "2" {
+			t.Errorf("Expected totalCount metadata 2, got %q", v)
+		}
+		if len(recs) != 1 || recs[0].NumRows() != 2 {
+			t.Fatalf("Expected one batch of 2 rows, got %d batches", len(recs))
+		}
+		data := recs[0].Column(4).(*array.Binary)
+		if !bytes.Equal(data.Value(0), []byte{0x00, 0xff}) || !data.IsNull(1) {
+			t.Errorf("Unexpected blob column: %v", data)
+		}
+		if qty := recs[0].Column(3).(*array.String); qty.Value(0) != "3" || qty.Value(1) != "n/a" {
+			t.Errorf("Unexpected qty column: %v", qty)
+		}
+	})
+
+	t.Run("ArrowInferred", func(t *testing.T) {
+		w := get(t, "/sqliter/rows?path=/shop.db/totals", "application/vnd.apache.arrow.stream")
+		schema, _ := readArrow(t, w.Body.Bytes())
+		if f, _ := schema.FieldsByName("doubled"); !arrow.TypeEqual(f[0].Type, arrow.PrimitiveTypes.Float64) {
+			t.Errorf("Expected doubled to be inferred as float64, got %s", f[0].Type)
+		}
+		if f, _ := schema.FieldsByName("label"); !arrow.TypeEqual(f[0].Type, arrow.BinaryTypes.String) {
+			t.Errorf("Expected label to be inferred as string, got %s", f[0].Type)
+		}
+	})
+
+	t.Run("Msgpack", func(t *testing.T) {
+		w := get(t, "/sqliter/rows?path=/shop.db/items", "application/x-msgpack;q=0.9, application/json;q=0.5")
+		if ct := w.Header().Get("Content-Type"); ct != "application/msgpack" {
+			t.Errorf("Unexpected Content-Type %q", ct)
+		}
+		var res map[string]interface{}
+		if err := msgpack.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("Failed to decode MessagePack: %v", err)
+		}
+		values, _ := res["values"].([]interface{})
+		if len(values) != 2 || res["totalCount"] != int8(2) {
+			t.Errorf("Unexpected result: %v", res)
+		}
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?path=/shop.db/items&format=xml", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("StreamArrow", func(t *testing.T) {
+		w := get(t, "/sqliter/stream?path=/shop.db/big&start=0&end=2500&format=arrow", "")
+		_, recs := readArrow(t, w.Body.Bytes())
+		rows := int64(0)
+		for _, rec := range recs {
+			rows += rec.NumRows()
+		}
+		if rows != 2500 || len(recs) < 2 {
+			t.Errorf("Expected 2500 rows in several batches, got %d in %d", rows, len(recs))
+		}
+		if !w.Flushed {
+			t.Errorf("Expected the stream to be flushed")
+		}
+	})
+
+	t.Run("StreamArrowMixed", func(t *testing.T) {
+		// Integers fill the first batch; text and reals follow in the second
+		w := get(t, "/sqliter/stream?path=/shop.db/mixed&start=0&end=1500&format=arrow", "")
+		schema, recs := readArrow(t, w.Body.Bytes())
+		for i := 0; i < 2; i++ {
+			if schema.Field(i).Type.ID() != arrow.STRING {
+				t.Errorf("Expected %s to be streamed as strings, got %s", schema.Field(i).Name, schema.Field(i).Type)
+			}
+		}
+		var rows int64
+		for _, rec := range recs {
+			for i := 0; i < 2; i++ {
+				if rec.Column(i).NullN() != 0 {
+					t.Errorf("Expected no nulls in %s, got %d", schema.Field(i).Name, rec.Column(i).NullN())
+				}
+			}
+			rows += rec.NumRows()
+		}
+		if rows != 1500 || len(recs) < 2 {
+			t.Fatalf("Expected 1500 rows in several batches, got %d in %d", rows, len(recs))
+		}
+		last := recs[len(recs)-1]
+		n := int(last.NumRows()) - 1
+		a, b := last.Column(0).(*array.String).Value(n), last.Column(1).(*array.String).Value(n)
+		if a != "row 1500" || b != "1500.5" {
+			t.Errorf("Expected the last row to be (row 1500, 1500.5), got (%s, %s)", a, b)
+		}
+	})
+
+	t.Run("StreamArrowMisfit", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := &arrowChunkEncoder{w: &buf}
+		chunks := []QueryResultChunk{
+			{Columns: []string{"n"}, ColumnTypes: []string{"INTEGER"}},
+			{Values: [][]interface{}{{int64(1)}, {nil}}},
+			{Values: [][]interface{}{{"n/a"}}},
+		}
+		for _, c := range chunks[:2] {
+			if err := enc.WriteChunk(c); err != nil {
+				t.Fatalf("WriteChunk failed: %v", err)
+			}
+		}
+		if err := enc.WriteChunk(chunks[2]); err == nil || !strings.Contains(err.Error(), "column n") {
+			t.Errorf("Expected text in an INTEGER column to fail the stream, got %v", err)
+		}
+
+		// The response is aborted rather than ended as if it were complete
+		func() {
+			defer func() {
+				if r := recover(); r != http.ErrAbortHandler {
+					t.Errorf("Expected the stream to be aborted, got %v", r)
+				}
+			}()
+			get(t, "/sqliter/stream?path=/shop.db/items&format=arrow", "")
+		}()
+
+		// An error from the callback stops the query
+		stop := errors.New("stop")
+		calls := 0
+		err := srv.engine.QueryStream(context.Background(), QueryOptions{BanquetPath: "/shop.db/big"}, func(chunk QueryResultChunk) error {
+			calls++
+			if len(chunk.Values) > 0 {
+				return stop
+			}
+			return nil
+		})
+		if !errors.Is(err, stop) || calls != 2 {
+			t.Errorf("Expected the stream to stop after its first rows, got %d calls: %v", calls, err)
+		}
+	})
+
+	t.Run("StreamJSON", func(t *testing.T) {
+		w := get(t, "/sqliter/stream?path=/shop.db/items", "")
+		if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Unexpected Content-Type %q", ct)
+		}
+		sc := bufio.NewScanner(strings.NewReader(w.Body.String()))
+		var chunks []QueryResultChunk
+		for sc.Scan() {
+			var c QueryResultChunk
+			if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+				t.Fatalf("Invalid chunk %q: %v", sc.Text(), err)
+			}
+			chunks = append(chunks, c)
+		}
+		if len(chunks) != 2 || len(chunks[0].Columns) != 6 || len(chunks[1].Values) != 2 {
+			t.Errorf("Expected a metadata chunk and a row chunk, got %+v", chunks)
+		}
+	})
+
+	t.Run("StreamMsgpack", func(t *testing.T) {
+		w := get(t, "/sqliter/stream?path=/shop.db/items&format=msgpack", "")
+		dec := msgpack.NewDecoder(bytes.NewReader(w.Body.Bytes()))
+		n := 0
+		for {
+			var c map[string]interface{}
+			if err := dec.Decode(&c); err != nil {
+				break
+			}
+			n++
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 chunks, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,308 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/darianmavgo/banquet"
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// ftsSuffix names the FTS5 shadow index sqliter keeps for a table: <table>_fts.
+const ftsSuffix = "_fts"
+
+// FTSIndex describes an FTS5 index that shadows a regular table.
+type FTSIndex struct {
+	Table   string   `json:"table"`
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+func ftsTableName(table string) string {
+	return table + ftsSuffix
+}
+
+// findFTSIndex returns the FTS5 index for table, or nil if there is none.
+func findFTSIndex(ctx context.Context, db *sql.DB, table string) (*FTSIndex, error) {
+	name := ftsTableName(table)
+	var ddl string
+	err := db.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&ddl)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(strings.ToLower(ddl), "fts5") {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	idx := &FTSIndex{Table: table, Name: name}
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err == nil {
+			idx.Columns = append(idx.Columns, col)
+		}
+	}
+	return idx, rows.Err()
+}
+
+// virtualTables returns the names of all virtual tables in the database.
+func virtualTables(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND sql LIKE 'CREATE VIRTUAL TABLE%'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			names[name] = true
+		}
+	}
+	return names, rows.Err()
+}
+
+// fts5ShadowSuffixes are the suffixes of the shadow tables FTS5 creates to back
+// a virtual table (e.g. orders_fts_data).
+var fts5ShadowSuffixes = []string{"_data", "_idx", "_content", "_docsize", "_config"}
+
+// isInternalTable reports whether a table is a virtual table or one of the
+// FTS5 shadow tables backing it. Other tables sharing a virtual table's name
+// as a prefix, such as docs_archive next to docs, are user tables.
+func isInternalTable(name string, virtual map[string]bool) bool {
+	if virtual[name] || strings.HasPrefix(name, "sqlite_") {
+		return true
+	}
+	for _, suffix := range fts5ShadowSuffixes {
+		if base, ok := strings.CutSuffix(name, suffix); ok && virtual[base] {
+			return true
+		}
+	}
+	return false
+}
+
+// ftsPhrase quotes user input as a single FTS5 phrase so operators and
+// punctuation in it are treated as text.
+func ftsPhrase(q string) string {
+	return `"` + strings.ReplaceAll(q, `"`, `""`) + `"`
+}
+
+// ftsPrefixQuery turns free text into an FTS5 query requiring every word as a
+// prefix, which suits search-as-you-type.
+func ftsPrefixQuery(q string) string {
+	words := strings.Fields(q)
+	for i, w := range words {
+		words[i] = ftsPhrase(w) + "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// ftsRankAlias names the ranked FTS5 matches joined to a quick-filtered table.
+const ftsRankAlias = `"__fts"`
+
+// applyQuickFilter narrows bq.Where to rows containing text. It uses the table's
+// FTS5 index when there is one and returns a JOIN of the ranked matches for
+// rankedQuery; otherwise it falls back to LIKE over the text columns and returns "".
+func applyQuickFilter(ctx context.Context, db *sql.DB, bq *banquet.Banquet, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", nil
+	}
+
+	fts, err := findFTSIndex(ctx, db, bq.Table)
+	if err != nil {
+		return "", err
+	}
+
+	var cond, rankJoin string
+	if fts != nil {
+		name := sqlite.QuoteIdentifier(fts.Name)
+		table := sqlite.QuoteIdentifier(bq.Table)
+		match := quoteLiteral(ftsPrefixQuery(text))
+		cond = fmt.Sprintf("%s.rowid IN (SELECT rowid FROM %s WHERE %s MATCH %s)", table, name, name, match)
+		rankJoin = fmt.Sprintf("JOIN (SELECT rowid AS fts_rowid, rank AS fts_rank FROM %s WHERE %s MATCH %s) AS %s ON %s.fts_rowid = %s.rowid",
+			name, name, match, ftsRankAlias, ftsRankAlias, table)
+	} else {
+		cols, err := textColumns(ctx, db, bq.Table)
+		if err != nil {
+			return "", err
+		}
+		pattern := quoteLiteral("%" + escapeLike(text) + "%")
+		conds := make([]string, len(cols))
+		for i, col := range cols {
+			conds[i] = fmt.Sprintf("%s LIKE %s ESCAPE '\\'", sqlite.QuoteIdentifier(col), pattern)
+		}
+		cond = strings.Join(conds, " OR ")
+		if cond == "" {
+			cond = "0"
+		}
+	}
+
+	if bq.Where != "" {
+		bq.Where = fmt.Sprintf("(%s) AND (%s)", bq.Where, cond)
+	} else {
+		bq.Where = cond
+	}
+	return rankJoin, nil
+}
+
+// rankedQuery composes bq with the ranked matches of a quick filter joined on
+// rowid, best matches first. The matches are ranked once, rather than by a
+// subquery per row.
+func rankedQuery(bq *banquet.Banquet, rankJoin string) string {
+	limit, offset := bq.Limit, bq.Offset
+	bq.Limit, bq.Offset = "", ""
+	composed := sqlite.Compose(bq)
+	bq.Limit, bq.Offset = limit, offset
+
+	// Splice the join in after the table; quoted names cannot contain the FROM clause
+	table := sqlite.QuoteIdentifier(bq.Table)
+	from := " FROM " + table
+	i := strings.Index(composed, from)
+	sel := composed[:i]
+	if sel == "SELECT *" {
+		sel = "SELECT " + table + ".*" // Leave out the join's columns
+	}
+	query := sel + from + " " + rankJoin + composed[i+len(from):] + " ORDER BY " + ftsRankAlias + ".fts_rank"
+	if limit != "" {
+		query += " LIMIT " + limit
+	}
+	if offset != "" {
+		query += " OFFSET " + offset
+	}
+	return query
+}
+
+// GetFTSIndex returns the FTS5 index of a table, or nil if it has none.
+func (e *Engine) GetFTSIndex(ctx context.Context, dbRelPath, table string) (*FTSIndex, error) {
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	return findFTSIndex(ctx, db, table)
+}
+
+// CreateFTSIndex builds an external-content FTS5 index over columns of table and
+// installs triggers that keep it in sync. With no columns, all text columns are indexed.
+// Like RebuildFTSIndex and DropFTSIndex, it returns ErrReadOnly unless
+// Config.Writable is on.
+func (e *Engine) CreateFTSIndex(ctx context.Context, dbRelPath, table string, columns []string) (*FTSIndex, error) {
+	if err := e.checkWritable(); err != nil {
+		return nil, err
+	}
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing, err := findFTSIndex(ctx, db, table); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("table %s already has an FTS index", table)
+	}
+
+	known, err := textColumns(ctx, db, table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if len(columns) == 0 {
+		columns = known
+	} else {
+		all, err := tableColumns(ctx, db, table)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		for _, c := range columns {
+			if !containsString(all, c) {
+				return nil, fmt.Errorf("no such column: %s", c)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s has no text columns to index", table)
+	}
+
+	name := ftsTableName(table)
+	qName := sqlite.QuoteIdentifier(name)
+	qTable := sqlite.QuoteIdentifier(table)
+
+	quoted := make([]string, len(columns))
+	newVals := make([]string, len(columns))
+	oldVals := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = sqlite.QuoteIdentifier(c)
+		newVals[i] = "new." + quoted[i]
+		oldVals[i] = "old." + quoted[i]
+	}
+	colList := strings.Join(quoted, ", ")
+	insertNew := fmt.Sprintf("INSERT INTO %s(rowid, %s) VALUES (new.rowid, %s);", qName, colList, strings.Join(newVals, ", "))
+	deleteOld := fmt.Sprintf("INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.rowid, %s);", qName, qName, colList, strings.Join(oldVals, ", "))
+
+	stmts := []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content=%s, content_rowid='rowid')", qName, colList, quoteLiteral(table)),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT ON %s BEGIN %s END", sqlite.QuoteIdentifier(name+"_ai"), qTable, insertNew),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER DELETE ON %s BEGIN %s END", sqlite.QuoteIdentifier(name+"_ad"), qTable, deleteOld),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN %s %s END", sqlite.QuoteIdentifier(name+"_au"), qTable, deleteOld, insertNew),
+		fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", qName, qName),
+	}
+	if err := execTx(ctx, db, stmts); err != nil {
+		return nil, fmt.Errorf("error creating FTS index: %w", err)
+	}
+	return &FTSIndex{Table: table, Name: name, Columns: columns}, nil
+}
+
+// RebuildFTSIndex repopulates a table's FTS5 index from the table contents.
+func (e *Engine) RebuildFTSIndex(ctx context.Context, dbRelPath, table string) error {
+	if err := e.checkWritable(); err != nil {
+		return err
+	}
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return err
+	}
+	fts, err := findFTSIndex(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	if fts == nil {
+		return fmt.Errorf("table %s has no FTS index", table)
+	}
+	name := sqlite.QuoteIdentifier(fts.Name)
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", name, name)); err != nil {
+		return fmt.Errorf("error rebuilding FTS index: %w", err)
+	}
+	return nil
+}
+
+// DropFTSIndex removes a table's FTS5 index and its sync triggers.
+func (e *Engine) DropFTSIndex(ctx context.Context, dbRelPath, table string) error {
+	if err := e.checkWritable(); err != nil {
+		return err
+	}
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return err
+	}
+	name := ftsTableName(table)
+	stmts := []string{
+		"DROP TRIGGER IF EXISTS " + sqlite.QuoteIdentifier(name+"_ai"),
+		"DROP TRIGGER IF EXISTS " + sqlite.QuoteIdentifier(name+"_ad"),
+		"DROP TRIGGER IF EXISTS " + sqlite.QuoteIdentifier(name+"_au"),
+		"DROP TABLE IF EXISTS " + sqlite.QuoteIdentifier(name),
+	}
+	if err := execTx(ctx, db, stmts); err != nil {
+		return fmt.Errorf("error dropping FTS index: %w", err)
+	}
+	return nil
+}
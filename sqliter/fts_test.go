@@ -0,0 +1,148 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestFTSIndexLifecycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "notes.db"), `
+		CREATE TABLE notes (id INTEGER PRIMARY KEY, title TEXT, body TEXT, score INTEGER);
+		INSERT INTO notes (title, body, score) VALUES
+			('groceries', 'buy apples and pears', 1),
+			('work', 'apple keynote notes', 2),
+			('travel', 'pack the passport', 3);
+	`)
+
+	cfg := &Config{ServeFolder: tmpDir}
+	engine := NewEngine(cfg)
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	// Without an index the quick filter falls back to LIKE
+	res, err := engine.Query(ctx, QueryOptions{BanquetPath: "/notes.db/notes", QuickFilter: "passport"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(res.Values) != 1 || !strings.Contains(res.SQL, "LIKE") {
+		t.Errorf("Expected 1 LIKE match, got %d rows, SQL: %s", len(res.Values), res.SQL)
+	}
+
+	if _, err := engine.CreateFTSIndex(ctx, "notes.db", "notes", nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected CreateFTSIndex to be refused when read-only, got %v", err)
+	}
+	cfg.Writable = true
+	idx, err := engine.CreateFTSIndex(ctx, "notes.db", "notes", nil)
+	if err != nil {
+		t.Fatalf("CreateFTSIndex failed: %v", err)
+	}
+	if idx.Name != "notes_fts" || len(idx.Columns) != 2 {
+		t.Errorf("Expected text columns title, body to be indexed, got %+v", idx)
+	}
+	if _, err := engine.CreateFTSIndex(ctx, "notes.db", "notes", nil); err == nil {
+		t.Errorf("Expected error creating a second index")
+	}
+
+	res, err = engine.Query(ctx, QueryOptions{BanquetPath: "/notes.db/notes", QuickFilter: "appl"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(res.Values) != 2 || !strings.Contains(res.SQL, "MATCH") || res.TotalCount != 2 {
+		t.Errorf("Expected 2 prefix matches via MATCH, got %d rows (total %d), SQL: %s", len(res.Values), res.TotalCount, res.SQL)
+	}
+
+	// Matches are ranked once through a join, and columns shared with the index stay unambiguous
+	res, err = engine.Query(ctx, QueryOptions{BanquetPath: "/notes.db/notes", QuickFilter: "appl", FilterWhere: "title = 'work'"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(res.Values) != 1 || len(res.Columns) != 4 || !strings.Contains(res.SQL, "JOIN") || !strings.Contains(res.SQL, "ORDER BY \"__fts\".fts_rank") {
+		t.Errorf("Expected 1 ranked match, got %d rows of %v, SQL: %s", len(res.Values), res.Columns, res.SQL)
+	}
+
+	// Triggers keep the index in sync with the table
+	db, _ := engine.openDB(ctx, "notes.db")
+	if _, err := db.Exec("INSERT INTO notes (title, body) VALUES ('orchard', 'apple trees')"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	res, _ = engine.Query(ctx, QueryOptions{BanquetPath: "/notes.db/notes", QuickFilter: "apple"})
+	if len(res.Values) != 3 {
+		t.Errorf("Expected 3 matches after insert, got %d", len(res.Values))
+	}
+
+	if err := engine.RebuildFTSIndex(ctx, "notes.db", "notes"); err != nil {
+		t.Errorf("RebuildFTSIndex failed: %v", err)
+	}
+	cfg.Writable = false
+	if err := engine.DropFTSIndex(ctx, "notes.db", "notes"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected DropFTSIndex to be refused when read-only, got %v", err)
+	}
+	cfg.Writable = true
+	if err := engine.DropFTSIndex(ctx, "notes.db", "notes"); err != nil {
+		t.Fatalf("DropFTSIndex failed: %v", err)
+	}
+	if idx, _ := engine.GetFTSIndex(ctx, "notes.db", "notes"); idx != nil {
+		t.Errorf("Expected no index after drop, got %+v", idx)
+	}
+	var triggers int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger'").Scan(&triggers)
+	if triggers != 0 {
+		t.Errorf("Expected triggers to be dropped, found %d", triggers)
+	}
+}
+
+func TestApiFTSIndex_ReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "t.db"), "CREATE TABLE t (a TEXT);")
+
+	server := NewServer(&Config{ServeFolder: tmpDir})
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/fts", strings.NewReader(`{"db":"t.db","table":"t"}`)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 on read-only server, got %d", w.Code)
+	}
+
+	server = NewServer(&Config{ServeFolder: tmpDir, Writable: true})
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/fts", strings.NewReader(`{"db":"t.db","table":"t","columns":["a"]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	db, _ := sql.Open("sqlite", filepath.Join(tmpDir, "t.db"))
+	defer db.Close()
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 't_fts'").Scan(&n)
+	if n != 1 {
+		t.Errorf("Expected t_fts to exist")
+	}
+}
+
+func TestIsInternalTable(t *testing.T) {
+	virtual := map[string]bool{"docs": true}
+	for name, want := range map[string]bool{
+		"docs":            true,
+		"docs_data":       true,
+		"docs_idx":        true,
+		"docs_content":    true,
+		"docs_docsize":    true,
+		"docs_config":     true,
+		"sqlite_sequence": true,
+		"docs_archive":    false,
+		"notes_data":      false,
+		"orders":          false,
+	} {
+		if got := isInternalTable(name, virtual); got != want {
+			t.Errorf("isInternalTable(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/darianmavgo/banquet"
+)
+
+// MaxHistoryEntries bounds the query history; the oldest unpinned entries are
+// pruned beyond it.
+const MaxHistoryEntries = 5000
+
+// historyQueueSize is how many entries can wait for the history writer; entries
+// beyond it are dropped rather than delaying queries.
+const historyQueueSize = 256
+
+// historyPruneInterval is how often the history writer prunes old entries.
+const historyPruneInterval = time.Minute
+
+// ErrHistoryNotFound is returned for an unknown history entry ID.
+var ErrHistoryNotFound = errors.New("history entry not found")
+
+// HistoryEntry is one executed query.
+type HistoryEntry struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"` // query, stream or sql
+	DB          string          `json:"db"`
+	BanquetPath string          `json:"banquetPath,omitempty"`
+	SQL         string          `json:"sql"`
+	DurationMs  float64         `json:"durationMs"`
+	RowCount    int64           `json:"rowCount"`
+	Error       string          `json:"error,omitempty"`
+	Pinned      bool            `json:"pinned"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Options     json.RawMessage `json:"options"` // QueryOptions or SQLOptions, for replay
+}
+
+// HistoryFilter selects history entries, newest first unless SortBy is "duration".
+type HistoryFilter struct {
+	Search        string  // Matched against the Banquet path, SQL and database
+	DB            string  // Exact database path
+	PinnedOnly    bool    // Only pinned entries
+	MinDurationMs float64 // Only entries at least this slow
+	SortBy        string  // "time" (default) or "duration"
+	Limit         int     // <= 0 means 100
+	Offset        int
+}
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS query_history (
+	id INTEGER PRIMARY KEY,
+	kind TEXT NOT NULL,
+	db TEXT NOT NULL DEFAULT '',
+	banquet_path TEXT NOT NULL DEFAULT '',
+	sql TEXT NOT NULL DEFAULT '',
+	duration_ms REAL NOT NULL,
+	row_count INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	pinned INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL,
+	options TEXT
+);
+CREATE INDEX IF NOT EXISTS query_history_duration ON query_history(duration_ms);`
+
+const historyColumns = "id, kind, db, banquet_path, sql, duration_ms, row_count, error, pinned, created_at, options"
+
+func scanHistoryEntry(scan func(...interface{}) error) (*HistoryEntry, error) {
+	var h HistoryEntry
+	var created string
+	var options sql.NullString
+	if err := scan(&h.ID, &h.Kind, &h.DB, &h.BanquetPath, &h.SQL, &h.DurationMs, &h.RowCount, &h.Error, &h.Pinned, &created, &options); err != nil {
+		return nil, err
+	}
+	h.CreatedAt, _ = time.Parse(time.RFC3339Nano, created)
+	if options.Valid {
+		h.Options = json.RawMessage(options.String)
+	}
+	return &h, nil
+}
+
+// AddHistory records an executed query. Entries beyond MaxHistoryEntries stay
+// until PruneHistory is called.
+func (c *Catalog) AddHistory(ctx context.Context, h HistoryEntry) (int64, error) {
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now()
+	}
+	res, err := c.db.ExecContext(ctx, `INSERT INTO query_history
+		(kind, db, banquet_path, sql, duration_ms, row_count, error, pinned, created_at, options)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.Kind, h.DB, h.BanquetPath, h.SQL, h.DurationMs, h.RowCount, h.Error, h.Pinned,
+		h.CreatedAt.UTC().Format(time.RFC3339Nano), nullJSON(h.Options))
+	if err != nil {
+		return 0, fmt.Errorf("catalog error: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("catalog error: %w", err)
+	}
+	return id, nil
+}
+
+// PruneHistory deletes the unpinned entries older than the last MaxHistoryEntries.
+func (c *Catalog) PruneHistory(ctx context.Context) error {
+	_, err := c.db.ExecContext(ctx, `DELETE FROM query_history WHERE pinned = 0
+		AND id <= (SELECT MAX(id) FROM query_history) - ?`, MaxHistoryEntries)
+	if err != nil {
+		return fmt.Errorf("catalog error: %w", err)
+	}
+	return nil
+}
+
+// ListHistory returns the history entries matching the filter.
+func (c *Catalog) ListHistory(ctx context.Context, f HistoryFilter) ([]HistoryEntry, error) {
+	var conds []string
+	var args []interface{}
+	if f.Search != "" {
+		conds = append(conds, `(banquet_path LIKE ? ESCAPE '\' OR sql LIKE ? ESCAPE '\' OR db LIKE ? ESCAPE '\')`)
+		pattern := "%" + escapeLike(f.Search) + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	if f.DB != "" {
+		conds = append(conds, "db = ?")
+		args = append(args, f.DB)
+	}
+	if f.PinnedOnly {
+		conds = append(conds, "pinned = 1")
+	}
+	if f.MinDurationMs > 0 {
+		conds = append(conds, "duration_ms >= ?")
+		args = append(args, f.MinDurationMs)
+	}
+
+	query := "SELECT " + historyColumns + " FROM query_history"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	if f.SortBy == "duration" {
+		query += " ORDER BY duration_ms DESC, id DESC"
+	} else {
+		query += " ORDER BY id DESC"
+	}
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	query += fmt.Sprintf(" LIMIT %d OFFSET %d", f.Limit, f.Offset)
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("catalog error: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]HistoryEntry, 0)
+	for rows.Next() {
+		h, err := scanHistoryEntry(rows.Scan)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, *h)
+	}
+	return entries, rows.Err()
+}
+
+// GetHistory returns one history entry.
+func (c *Catalog) GetHistory(ctx context.Context, id int64) (*HistoryEntry, error) {
+	h, err := scanHistoryEntry(c.db.QueryRowContext(ctx, "SELECT "+historyColumns+" FROM query_history WHERE id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrHistoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("catalog error: %w", err)
+	}
+	return h, nil
+}
+
+// PinHistory pins or unpins an entry. Pinned entries are never pruned or cleared.
+func (c *Catalog) PinHistory(ctx context.Context, id int64, pinned bool) error {
+	res, err := c.db.ExecContext(ctx, "UPDATE query_history SET pinned = ? WHERE id = ?", pinned, id)
+	if err != nil {
+		return fmt.Errorf("catalog error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrHistoryNotFound
+	}
+	return nil
+}
+
+// ClearHistory deletes all unpinned entries.
+func (c *Catalog) ClearHistory(ctx context.Context) error {
+	if _, err := c.db.ExecContext(ctx, "DELETE FROM query_history WHERE pinned = 0"); err != nil {
+		return fmt.Errorf("catalog error: %w", err)
+	}
+	return nil
+}
+
+// recordHistory stores an executed query when Config.QueryHistory is on. Failures
+// only affect the history, never the query, so they are logged and dropped.
+func (e *Engine) recordHistory(h HistoryEntry, opts interface{}, start time.Time, err error) {
+	if !e.config.QueryHistory {
+		return
+	}
+	h.CreatedAt = start
+	h.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		h.Error = err.Error()
+	}
+	if h.DB == "" && h.BanquetPath != "" {
+		if bq, perr := banquet.ParseNested(h.BanquetPath); perr == nil {
+			h.DB = strings.TrimPrefix(bq.DataSetPath, "/")
+		}
+	}
+	h.Options, _ = json.Marshal(opts)
+
+	// Written in the background, so queries never wait on the catalog
+	e.historyMu.Lock()
+	defer e.historyMu.Unlock()
+	if e.historyQueue == nil {
+		e.historyQueue = make(chan HistoryEntry, historyQueueSize)
+		e.historyFlush = make(chan chan struct{})
+		e.historyDone = make(chan struct{})
+		go e.writeHistory(e.historyQueue, e.historyFlush, e.historyDone)
+	}
+	select {
+	case e.historyQueue <- h:
+	default:
+		fmt.Printf("[Engine.History] Queue full, dropped query: %s\n", h.SQL)
+	}
+}
+
+// writeHistory stores queued entries until the queue is closed, pruning the
+// history periodically rather than on every insert. A flush request is
+// answered once the entries queued before it are stored.
+func (e *Engine) writeHistory(queue <-chan HistoryEntry, flush <-chan chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+
+	added := false
+	prune := func() {
+		if !added {
+			return
+		}
+		added = false
+		catalog, err := e.Catalog()
+		if err == nil {
+			err = catalog.PruneHistory(context.Background())
+		}
+		if err != nil {
+			fmt.Printf("[Engine.History] Failed to prune history: %v\n", err)
+		}
+	}
+	defer prune()
+
+	add := func(h HistoryEntry) {
+		catalog, err := e.Catalog()
+		if err == nil {
+			_, err = catalog.AddHistory(context.Background(), h)
+		}
+		if err != nil {
+			fmt.Printf("[Engine.History] Failed to record query: %v\n", err)
+			return
+		}
+		added = true
+	}
+
+	for {
+		select {
+		case h, ok := <-queue:
+			if !ok {
+				return
+			}
+			add(h)
+		case reply := <-flush:
+			for queued := len(queue); queued > 0; queued-- {
+				if h, ok := <-queue; ok {
+					add(h)
+				}
+			}
+			close(reply)
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
+
+// FlushHistory waits until the queries recorded so far are in the history.
+func (e *Engine) FlushHistory() {
+	e.historyMu.Lock()
+	flush, done := e.historyFlush, e.historyDone
+	e.historyMu.Unlock()
+	if flush == nil {
+		return
+	}
+	reply := make(chan struct{})
+	select {
+	case flush <- reply:
+		<-reply
+	case <-done:
+	}
+}
+
+// stopHistory stores the queries recorded so far, prunes the history and stops
+// the history writer.
+func (e *Engine) stopHistory() {
+	e.historyMu.Lock()
+	queue, done := e.historyQueue, e.historyDone
+	e.historyQueue, e.historyFlush, e.historyDone = nil, nil, nil
+	e.historyMu.Unlock()
+	if queue != nil {
+		close(queue)
+		<-done
+	}
+}
+
+// ReplayHistory runs a recorded query again with its original options.
+// Streamed queries are replayed as a regular Query.
+func (e *Engine) ReplayHistory(ctx context.Context, id int64) (*QueryResult, error) {
+	catalog, err := e.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	h, err := catalog.GetHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Kind == "sql" {
+		var opts SQLOptions
+		if err := json.Unmarshal(h.Options, &opts); err != nil {
+			return nil, fmt.Errorf("invalid recorded options: %w", err)
+		}
+		return e.ExecuteSQL(ctx, opts)
+	}
+	var opts QueryOptions
+	if err := json.Unmarshal(h.Options, &opts); err != nil {
+		return nil, fmt.Errorf("invalid recorded options: %w", err)
+	}
+	return e.Query(ctx, opts)
+}
@@ -0,0 +1,124 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestQueryHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, total REAL);
+		INSERT INTO orders VALUES (1, 5), (2, 50), (3, 500);
+	`)
+	cfg := &Config{ServeFolder: tmpDir, CatalogPath: filepath.Join(t.TempDir(), "catalog.db"), QueryHistory: true}
+	engine := NewEngine(cfg)
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	if _, err := engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/orders", FilterWhere: "total > 10"}); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, err := engine.ExecuteSQL(ctx, SQLOptions{DB: "shop.db", SQL: "SELECT SUM(total) FROM orders"}); err != nil {
+		t.Fatalf("ExecuteSQL failed: %v", err)
+	}
+	if _, err := engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/missing"}); err == nil {
+		t.Fatal("Expected an error for a missing table")
+	}
+
+	// Queries are recorded in the background
+	engine.FlushHistory()
+	catalog, err := engine.Catalog()
+	if err != nil {
+		t.Fatalf("Catalog failed: %v", err)
+	}
+	entries, err := catalog.ListHistory(ctx, HistoryFilter{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 history entries, got %+v (%v)", entries, err)
+	}
+	failed, console, query := entries[0], entries[1], entries[2]
+	if failed.Error == "" || failed.DB != "shop.db" {
+		t.Errorf("Expected the failed query to be recorded with its error, got %+v", failed)
+	}
+	if console.Kind != "sql" || console.RowCount != 1 {
+		t.Errorf("Unexpected console entry: %+v", console)
+	}
+	if query.Kind != "query" || query.RowCount != 2 || query.SQL == "" || query.DB != "shop.db" {
+		t.Errorf("Unexpected query entry: %+v", query)
+	}
+
+	if found, _ := catalog.ListHistory(ctx, HistoryFilter{Search: "SUM("}); len(found) != 1 || found[0].ID != console.ID {
+		t.Errorf("Expected search to find the console query, got %+v", found)
+	}
+
+	// Replay reruns the original options, filter included
+	res, err := engine.ReplayHistory(ctx, query.ID)
+	if err != nil || len(res.Values) != 2 {
+		t.Fatalf("Replay failed: %+v (%v)", res, err)
+	}
+
+	engine.FlushHistory()
+	if err := catalog.PinHistory(ctx, query.ID, true); err != nil {
+		t.Fatalf("PinHistory failed: %v", err)
+	}
+	if err := catalog.ClearHistory(ctx); err != nil {
+		t.Fatalf("ClearHistory failed: %v", err)
+	}
+	if left, _ := catalog.ListHistory(ctx, HistoryFilter{}); len(left) != 1 || !left[0].Pinned {
+		t.Errorf("Expected only the pinned entry to survive clearing, got %+v", left)
+	}
+
+	t.Run("Prune", func(t *testing.T) {
+		// Entries older than the last MaxHistoryEntries go, unless pinned
+		if _, err := catalog.db.Exec("INSERT INTO query_history (id, kind, duration_ms, created_at) VALUES (?, 'sql', 0, '')", query.ID+MaxHistoryEntries+1); err != nil {
+			t.Fatalf("Failed to insert entry: %v", err)
+		}
+		if err := catalog.PruneHistory(ctx); err != nil {
+			t.Fatalf("PruneHistory failed: %v", err)
+		}
+		left, _ := catalog.ListHistory(ctx, HistoryFilter{})
+		if len(left) != 2 || !left[1].Pinned {
+			t.Errorf("Expected the pinned and the newest entry, got %+v", left)
+		}
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		// Flushing stores queued entries but leaves pruning to the writer's ticker
+		left, _ := catalog.ListHistory(ctx, HistoryFilter{})
+		if _, err := catalog.db.Exec("INSERT INTO query_history (id, kind, duration_ms, created_at) VALUES (?, 'sql', 0, '')", left[0].ID+MaxHistoryEntries+1); err != nil {
+			t.Fatalf("Failed to insert entry: %v", err)
+		}
+		if _, err := engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/orders"}); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		engine.FlushHistory()
+		if all, _ := catalog.ListHistory(ctx, HistoryFilter{}); len(all) != len(left)+2 {
+			t.Errorf("Expected %d entries after flushing, got %+v", len(left)+2, all)
+		}
+		if engine.historyQueue == nil {
+			t.Error("Expected the history writer to keep running after a flush")
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := &Server{config: cfg, engine: engine}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("POST", "/sqliter/history?action=replay&id=999", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d: %s", w.Code, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/history?pinned=true", nil))
+		var body []HistoryEntry
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || len(body) != 1 {
+			t.Errorf("Expected 1 pinned entry, got %+v (%v)", body, err)
+		}
+	})
+}
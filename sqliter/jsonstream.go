@@ -0,0 +1,295 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonFlushSize is how much encoded JSON is buffered before it is written out.
+const jsonFlushSize = 32 << 10
+
+var jsonBufPool = sync.Pool{New: func() interface{} {
+	b := make([]byte, 0, jsonFlushSize+4096)
+	return &b
+}}
+
+// QueryJSON runs a Banquet query like Query and writes the JSON encoding of its
+// result to w. Rows are encoded as they are scanned rather than collected
+// first; only pages small enough for the result cache also keep their encoding
+// for it.
+//
+// If the query fails after part of the result was written, the error is
+// returned and the JSON is left incomplete.
+func (e *Engine) QueryJSON(ctx context.Context, opts QueryOptions, w io.Writer) error {
+	start := time.Now()
+	h := HistoryEntry{Kind: "query", BanquetPath: opts.BanquetPath}
+	var err error
+	h.SQL, h.RowCount, err = e.queryJSON(ctx, opts, w)
+	e.recordHistory(h, opts, start, err)
+	return err
+}
+
+func (e *Engine) queryJSON(ctx context.Context, opts QueryOptions, w io.Writer) (string, int64, error) {
+	page, cached, err := e.openPage(ctx, opts, true)
+	if err != nil {
+		return "", 0, err
+	}
+	if cached != nil {
+		return cached.result.SQL, cached.rowCount, writeCachedJSON(w, cached)
+	}
+	defer page.close()
+	last := time.Now()
+
+	head, tail, err := jsonEnvelope(page.res)
+	if err != nil {
+		return "", 0, err
+	}
+
+	bufp := jsonBufPool.Get().(*[]byte)
+	buf := append((*bufp)[:0], head...)
+	defer func() {
+		*bufp = buf[:0]
+		jsonBufPool.Put(bufp)
+	}()
+
+	// Pages small enough for the result cache also keep their encoded rows for it
+	var kept []byte
+	keep := page.cacheKey != ""
+	keptSize := resultSize(page.res)
+
+	pq, columns := page.pq, page.res.Columns
+	var rowid interface{}
+	blobURL := func(i int) string { return pq.blobURL(columns[i], rowid) }
+
+	// Values are encoded straight from the driver's, which are only valid
+	// until the next row is scanned
+	cells := make([]rawValue, len(columns))
+	if pq.withRowID {
+		cells = append(cells, rawValue{})
+	}
+	cellPtrs := make([]interface{}, len(cells))
+	for i := range cells {
+		cellPtrs[i] = &cells[i]
+	}
+	row := make([]interface{}, len(columns))
+
+	var n int64
+	for page.rows.Next() {
+		if err := page.rows.Scan(cellPtrs...); err != nil {
+			continue
+		}
+		for i := range row {
+			row[i] = cells[i].v
+		}
+		if pq.withRowID {
+			rowid = cells[len(columns)].v
+		}
+		start := len(buf)
+		if n > 0 {
+			buf = append(buf, ',')
+		}
+		if opts.TypedValues {
+			buf = appendTypedJSONRow(buf, row, blobURL)
+		} else {
+			buf = appendJSONRow(buf, row)
+		}
+		n++
+
+		if keep {
+			if keptSize += int64(len(buf) - start); keptSize > e.results.maxBytes/4 {
+				keep, kept = false, nil
+			} else {
+				kept = append(kept, buf[start:]...)
+			}
+		}
+		if len(buf) >= jsonFlushSize {
+			if _, err := w.Write(buf); err != nil {
+				return page.res.SQL, n, err
+			}
+			buf = buf[:0]
+		}
+	}
+	if err := page.rows.Err(); err != nil {
+		return page.res.SQL, n, fmt.Errorf("error reading rows: %w", err)
+	}
+	buf = append(buf, tail...)
+	buf = append(buf, '\n')
+	if _, err := w.Write(buf); err != nil {
+		return page.res.SQL, n, err
+	}
+	fmt.Printf("[Engine.Query] Row Scan and Encode took %v\n", time.Since(last))
+
+	var entry *cachedResult
+	if keep {
+		meta := *page.res
+		entry = &cachedResult{result: &meta, rows: kept, rowCount: n}
+	}
+	page.finish(e, entry)
+	return page.res.SQL, n, nil
+}
+
+// rawValue scans a value as the driver returns it, without the copy of byte
+// slices that scanning into an interface{} makes.
+type rawValue struct{ v interface{} }
+
+func (r *rawValue) Scan(src interface{}) error {
+	r.v = src
+	return nil
+}
+
+// writeCachedJSON writes a result QueryJSON stored in the cache, marked as cached.
+func writeCachedJSON(w io.Writer, entry *cachedResult) error {
+	meta := *entry.result
+	meta.Cached = true
+	head, tail, err := jsonEnvelope(&meta)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 0, len(head)+len(entry.rows)+len(tail)+1)
+	b = append(append(append(b, head...), entry.rows...), tail...)
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// jsonEnvelope encodes a result without its rows, split where the rows go.
+func jsonEnvelope(res *QueryResult) (head, tail []byte, err error) {
+	meta := *res
+	meta.Values = [][]interface{}{}
+	b, err := json.Marshal(&meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Strings are escaped, so the first unescaped key is the values field
+	const key = `"values":[`
+	i := bytes.Index(b, []byte(key))
+	if i < 0 {
+		return nil, nil, fmt.Errorf("unexpected result encoding")
+	}
+	i += len(key)
+	return b[:i], b[i:], nil
+}
+
+// appendJSONRow appends a row as a JSON array, encoding values as
+// encoding/json does.
+func appendJSONRow(b []byte, row []interface{}) []byte {
+	b = append(b, '[')
+	for i, v := range row {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONValue(b, v)
+	}
+	return append(b, ']')
+}
+
+func appendJSONValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "null"...)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case float64:
+		return appendJSONFloat(b, v)
+	case string:
+		return appendJSONString(b, v)
+	case []byte:
+		return appendJSONString(b, string(v))
+	case bool:
+		return strconv.AppendBool(b, v)
+	case time.Time:
+		if y := v.Year(); y >= 0 && y < 10000 {
+			b = append(b, '"')
+			b = v.AppendFormat(b, time.RFC3339Nano)
+			return append(b, '"')
+		}
+	}
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return append(b, "null"...)
+	}
+	return append(b, enc...)
+}
+
+// appendJSONFloat formats a float like encoding/json. Infinities, which JSON
+// cannot represent, are written as null.
+func appendJSONFloat(b []byte, f float64) []byte {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return append(b, "null"...)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Shorten e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// appendJSONString quotes a string like encoding/json, including its escaping
+// of HTML characters, invalid UTF-8 and the JavaScript line separators.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
@@ -0,0 +1,131 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestAppendJSONValue(t *testing.T) {
+	for _, v := range []interface{}{
+		nil, int64(0), int64(-1 << 63), int64(1<<53 + 1), true,
+		0.0, -0.0, 1.5, 1e-7, 1.234e-9, 1e21, 123456789012345680000.0, math.MaxFloat64, math.SmallestNonzeroFloat64,
+		"", "plain", `quote " backslash \ slash /`, "<b>&amp;</b>", "\b\f\n\r\t\x00\x1f\x7f",
+		"caf\u00e9 \u2028 \u2029 \U0001F600", "bad \xff\xfe utf8", []byte("bytes <\xff>"),
+		time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC), time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600)),
+		map[string]int{"other": 1},
+	} {
+		want := v
+		if b, ok := v.([]byte); ok {
+			want = string(b) // Rows carry text, as Query converts bytes to strings
+		}
+		expected, err := json.Marshal(want)
+		if err != nil {
+			t.Fatalf("json.Marshal(%#v): %v", v, err)
+		}
+		if got := appendJSONValue(nil, v); !bytes.Equal(got, expected) {
+			t.Errorf("appendJSONValue(%#v) = %s, want %s", v, got, expected)
+		}
+	}
+	if got := string(appendJSONValue(nil, math.Inf(1))); got != "null" {
+		t.Errorf("Expected null for infinity, got %s", got)
+	}
+}
+
+func TestQueryJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "mixed.db"), `
+		CREATE TABLE vals (id INTEGER PRIMARY KEY, label TEXT, amount REAL, data BLOB, at DATETIME);
+		INSERT INTO vals (label, amount, data, at) VALUES
+			('<tag> & "q"', 0.000001234, x'c328', '2024-05-06 07:08:09'),
+			(NULL, 1e300, NULL, NULL),
+			('line' || char(10) || 'break', -2, x'', 'not a date'),
+			(42, 9007199254740993, 'text', 12);
+		CREATE TABLE big (n INTEGER, pad TEXT);
+		WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 3000)
+		INSERT INTO big SELECT i, printf('%040d', i) FROM c;
+	`)
+	ctx := context.Background()
+
+	for _, path := range []string{"/mixed.db/vals", "/mixed.db/big"} {
+		t.Run(path, func(t *testing.T) {
+			engine := NewEngine(&Config{ServeFolder: tmpDir})
+			defer engine.CloseAll()
+			opts := QueryOptions{BanquetPath: path, AllowOverride: true, Limit: 3000}
+
+			res, err := engine.Query(ctx, opts)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var want bytes.Buffer
+			json.NewEncoder(&want).Encode(res)
+
+			var got bytes.Buffer
+			if err := engine.QueryJSON(ctx, opts, &got); err != nil {
+				t.Fatalf("QueryJSON failed: %v", err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("QueryJSON output differs from Query:\n got %.300s\nwant %.300s", got.String(), want.String())
+			}
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		engine := NewEngine(&Config{ServeFolder: tmpDir})
+		defer engine.CloseAll()
+		var out bytes.Buffer
+		if err := engine.QueryJSON(ctx, QueryOptions{BanquetPath: "/mixed.db/missing"}, &out); err == nil || out.Len() != 0 {
+			t.Errorf("Expected an error before any output, got %v and %q", err, out.String())
+		}
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		engine := NewEngine(&Config{ServeFolder: tmpDir, ResultCacheBytes: 1 << 20})
+		defer engine.CloseAll()
+		opts := QueryOptions{BanquetPath: "/mixed.db/vals"}
+		var first, second bytes.Buffer
+		if err := engine.QueryJSON(ctx, opts, &first); err != nil {
+			t.Fatalf("QueryJSON failed: %v", err)
+		}
+		if err := engine.QueryJSON(ctx, opts, &second); err != nil {
+			t.Fatalf("QueryJSON failed: %v", err)
+		}
+		if strings.Contains(first.String(), `"cached"`) || !strings.Contains(second.String(), `"cached":true`) {
+			t.Errorf("Expected the second result from the cache")
+		}
+		if got := strings.Replace(second.String(), `,"cached":true`, "", 1); got != first.String() {
+			t.Errorf("Cached result differs:\n got %.300s\nwant %.300s", got, first.String())
+		}
+		var a, b QueryResult
+		json.Unmarshal(first.Bytes(), &a)
+		json.Unmarshal(second.Bytes(), &b)
+		if len(a.Values) != 4 || len(b.Values) != 4 {
+			t.Errorf("Expected 4 rows in both results, got %d and %d", len(a.Values), len(b.Values))
+		}
+		// The cache keeps the encoded rows rather than their values
+		for _, el := range engine.results.entries {
+			if entry := el.Value.(*cachedResult); entry.result.Values != nil || len(entry.rows) == 0 {
+				t.Errorf("Expected only encoded rows in the cache, got %+v", entry)
+			}
+		}
+
+		// Pages beyond a quarter of the cache are streamed without being kept
+		small := NewEngine(&Config{ServeFolder: tmpDir, ResultCacheBytes: 16 << 10})
+		defer small.CloseAll()
+		big := QueryOptions{BanquetPath: "/mixed.db/big", AllowOverride: true, Limit: 3000}
+		var out bytes.Buffer
+		if err := small.QueryJSON(ctx, big, &out); err != nil {
+			t.Fatalf("QueryJSON failed: %v", err)
+		}
+		if stats := small.CacheStats(); stats.Entries != 0 {
+			t.Errorf("Expected a large page not to be cached, got %+v", stats)
+		}
+	})
+}
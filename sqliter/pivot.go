@@ -0,0 +1,170 @@
+package sqliter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// DefaultMaxPivotValues caps how many distinct pivot values become columns.
+const DefaultMaxPivotValues = 50
+
+// PivotOptions describes a pivot of the table selected by BanquetPath.
+type PivotOptions struct {
+	BanquetPath     string
+	FilterWhere     string   // SQL fragment
+	FilterModelJSON string   // AgGrid Filter Model JSON
+	RowKeys         []string // Columns identifying each output row
+	PivotColumn     string   // Column whose distinct values become output columns
+	ValueColumn     string   // Column aggregated into each cell; may be empty for count
+	Aggregate       string   // count (default), sum, avg, min, max or group_concat
+	MaxPivotValues  int      // <= 0 uses DefaultMaxPivotValues
+}
+
+// Pivot aggregates ValueColumn by RowKeys, spreading the distinct values of
+// PivotColumn into one result column each. It fails if the pivot column has
+// more than MaxPivotValues distinct values.
+func (e *Engine) Pivot(ctx context.Context, opts PivotOptions) (*QueryResult, error) {
+	if len(opts.RowKeys) == 0 || opts.PivotColumn == "" {
+		return nil, fmt.Errorf("row keys and pivot column required")
+	}
+	if opts.MaxPivotValues <= 0 {
+		opts.MaxPivotValues = DefaultMaxPivotValues
+	}
+	agg := Aggregate{Func: strings.ToLower(opts.Aggregate), Column: opts.ValueColumn}
+	if agg.Func == "" {
+		agg.Func = "count"
+	}
+	if err := agg.validate(); err != nil {
+		return nil, err
+	}
+
+	pq, err := e.prepareQuery(ctx, QueryOptions{
+		BanquetPath:     opts.BanquetPath,
+		FilterWhere:     opts.FilterWhere,
+		FilterModelJSON: opts.FilterModelJSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cols, err := tableColumns(ctx, pq.db, pq.bq.Table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	for _, c := range append(append([]string{}, opts.RowKeys...), opts.PivotColumn, opts.ValueColumn) {
+		if c != "" && c != "*" && !containsString(cols, c) {
+			return nil, fmt.Errorf("no such column: %s", c)
+		}
+	}
+
+	from := "FROM " + sqlite.QuoteIdentifier(pq.bq.Table)
+	if pq.bq.Where != "" {
+		from += " WHERE " + pq.bq.Where
+	}
+	pivotCol := sqlite.QuoteIdentifier(opts.PivotColumn)
+
+	// Discover the pivot values, one more than allowed to detect overflow
+	rows, err := pq.db.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT %s %s ORDER BY 1 LIMIT %d",
+		pivotCol, from, opts.MaxPivotValues+1))
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	var pivotValues []interface{}
+	for rows.Next() {
+		var v interface{}
+		if err := rows.Scan(&v); err == nil {
+			pivotValues = append(pivotValues, normalizeValue(v))
+		}
+	}
+	rows.Close()
+	if len(pivotValues) > opts.MaxPivotValues {
+		return nil, fmt.Errorf("pivot column %s has more than %d distinct values", opts.PivotColumn, opts.MaxPivotValues)
+	}
+
+	keys := make([]string, len(opts.RowKeys))
+	for i, k := range opts.RowKeys {
+		keys[i] = sqlite.QuoteIdentifier(k)
+	}
+	selects := append([]string{}, keys...)
+
+	// Output column names must stay distinct from the row keys and from each
+	// other, even for values like 1 and '1' that print alike
+	labels := make(map[string]bool)
+	for _, k := range opts.RowKeys {
+		labels[strings.ToLower(k)] = true
+	}
+
+	// Count non-null values when a column is given, rows otherwise
+	cell := "1"
+	if agg.Column != "" && agg.Column != "*" {
+		cell = sqlite.QuoteIdentifier(agg.Column)
+	}
+	for _, v := range pivotValues {
+		cond := pivotCol + " IS NULL"
+		label := "(null)"
+		if v != nil {
+			lit, err := sqlLiteral(v)
+			if err != nil {
+				return nil, err
+			}
+			cond = pivotCol + " = " + lit
+			label = fmt.Sprintf("%v", v)
+		}
+		label = uniqueLabel(label, labels)
+		selects = append(selects, fmt.Sprintf("%s(CASE WHEN %s THEN %s END) AS %s",
+			strings.ToUpper(agg.Func), cond, cell, sqlite.QuoteIdentifier(label)))
+	}
+
+	groupBy := strings.Join(keys, ", ")
+	query := fmt.Sprintf("SELECT %s %s GROUP BY %s ORDER BY %s", strings.Join(selects, ", "), from, groupBy, groupBy)
+	if pq.bq.Limit != "" {
+		query += " LIMIT " + pq.bq.Limit
+	}
+	if pq.bq.Offset != "" {
+		query += " OFFSET " + pq.bq.Offset
+	}
+
+	result := &QueryResult{SQL: query, Values: make([][]interface{}, 0)}
+	_ = pq.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 %s GROUP BY %s)", from, groupBy)).Scan(&result.TotalCount)
+
+	rows, err = pq.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	result.Columns, err = rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("error getting columns: %w", err)
+	}
+	values := make([]interface{}, len(result.Columns))
+	valuePtrs := make([]interface{}, len(result.Columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			continue
+		}
+		rowData := make([]interface{}, len(values))
+		for i, v := range values {
+			rowData[i] = normalizeValue(v)
+		}
+		result.Values = append(result.Values, rowData)
+	}
+	return result, rows.Err()
+}
+
+// uniqueLabel returns label, suffixed with " (2)", " (3)"... if it is already
+// in used, ignoring case as SQLite does for column names, and records it.
+func uniqueLabel(label string, used map[string]bool) string {
+	unique := label
+	for n := 2; used[strings.ToLower(unique)]; n++ {
+		unique = fmt.Sprintf("%s (%d)", label, n)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
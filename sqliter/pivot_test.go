@@ -0,0 +1,107 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestPivot(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "Expenses.csv.db"), `
+		CREATE TABLE expenses (month TEXT, category TEXT, amount REAL);
+		INSERT INTO expenses VALUES
+			('2024-01', 'food', 10), ('2024-01', 'food', 5), ('2024-01', 'rent', 100),
+			('2024-02', 'food', 8), ('2024-02', 'travel', 40), ('2024-02', NULL, 1);
+		CREATE TABLE codes (month TEXT, code, n INTEGER);
+		INSERT INTO codes VALUES ('2024-01', 1, 1), ('2024-01', '1', 2), ('2024-01', 'month', 3);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	res, err := engine.Pivot(ctx, PivotOptions{
+		BanquetPath: "/Expenses.csv.db/expenses",
+		RowKeys:     []string{"month"},
+		PivotColumn: "category",
+		ValueColumn: "amount",
+		Aggregate:   "sum",
+	})
+	if err != nil {
+		t.Fatalf("Pivot failed: %v", err)
+	}
+
+	expectedCols := []string{"month", "(null)", "food", "rent", "travel"}
+	if len(res.Columns) != len(expectedCols) {
+		t.Fatalf("Expected columns %v, got %v", expectedCols, res.Columns)
+	}
+	for i, c := range expectedCols {
+		if res.Columns[i] != c {
+			t.Errorf("Column %d: expected %s, got %s", i, c, res.Columns[i])
+		}
+	}
+	if res.TotalCount != 2 || len(res.Values) != 2 {
+		t.Fatalf("Expected 2 month rows, got %+v", res)
+	}
+	jan := res.Values[0]
+	if jan[0] != "2024-01" || jan[2] != 15.0 || jan[3] != 100.0 || jan[4] != nil {
+		t.Errorf("Unexpected January row: %v", jan)
+	}
+
+	t.Run("Cap on pivot values", func(t *testing.T) {
+		_, err := engine.Pivot(ctx, PivotOptions{
+			BanquetPath:    "/Expenses.csv.db/expenses",
+			RowKeys:        []string{"month"},
+			PivotColumn:    "category",
+			MaxPivotValues: 2,
+		})
+		if err == nil {
+			t.Errorf("Expected error when pivot values exceed the cap")
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/pivot?path=/Expenses.csv.db/expenses&rows=category&pivot=month", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body QueryResult
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		// Counts per category and month; food appears twice in January
+		if len(body.Columns) != 3 || body.Values[1][0] != "food" || body.Values[1][1] != float64(2) {
+			t.Errorf("Unexpected pivot response: %+v", body)
+		}
+	})
+
+	t.Run("DuplicateLabels", func(t *testing.T) {
+		res, err := engine.Pivot(ctx, PivotOptions{
+			BanquetPath: "/Expenses.csv.db/codes",
+			RowKeys:     []string{"month"},
+			PivotColumn: "code",
+			ValueColumn: "n",
+			Aggregate:   "sum",
+		})
+		if err != nil {
+			t.Fatalf("Pivot failed: %v", err)
+		}
+		// Integers sort before text
+		want := []string{"month", "1", "1 (2)", "month (2)"}
+		if fmt.Sprint(res.Columns) != fmt.Sprint(want) {
+			t.Fatalf("Expected columns %v, got %v", want, res.Columns)
+		}
+		if fmt.Sprint(res.Values[0]) != "[2024-01 1 2 3]" {
+			t.Errorf("Unexpected row %v", res.Values[0])
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+const (
+	// DefaultProfileTopN is how many most frequent values ProfileTable reports per column.
+	DefaultProfileTopN = 10
+	// DefaultProfileBins is the number of histogram bins for numeric columns.
+	DefaultProfileBins = 10
+)
+
+// ProfileOptions controls ProfileTable.
+type ProfileOptions struct {
+	FilterWhere     string   // SQL fragment
+	FilterModelJSON string   // AgGrid Filter Model JSON
+	Columns         []string // Columns to profile; empty means all
+	SampleSize      int      // Profile a random sample of this many rows when the table is larger; 0 profiles every row
+	TopN            int      // <= 0 uses DefaultProfileTopN
+	Bins            int      // <= 0 uses DefaultProfileBins
+}
+
+// HistogramBin counts the numeric values falling in [Low, High).
+// The last bin also includes its upper bound.
+type HistogramBin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int64   `json:"count"`
+}
+
+// ColumnProfile summarizes the contents of one column.
+type ColumnProfile struct {
+	Name             string           `json:"name"`
+	DeclaredType     string           `json:"declaredType"`
+	StorageClasses   map[string]int64 `json:"storageClasses"` // typeof() -> rows
+	Nulls            int64            `json:"nulls"`
+	Empty            int64            `json:"empty"` // Empty strings
+	DistinctEstimate int64            `json:"distinctEstimate"`
+	Min              interface{}      `json:"min"`
+	Max              interface{}      `json:"max"`
+	Mean             *float64         `json:"mean,omitempty"`
+	Stddev           *float64         `json:"stddev,omitempty"`
+	TopValues        []ValueCount     `json:"topValues"`
+	Histogram        []HistogramBin   `json:"histogram,omitempty"`
+}
+
+// TableProfile is the result of ProfileTable.
+type TableProfile struct {
+	Table      string          `json:"table"`
+	RowCount   int64           `json:"rowCount"`
+	Sampled    bool            `json:"sampled"`
+	SampleSize int64           `json:"sampleSize"` // Rows actually profiled
+	Columns    []ColumnProfile `json:"columns"`
+}
+
+// profileSampleTable holds the random sample of one ProfileTable call, on a
+// connection that is closed with it.
+const profileSampleTable = "sqliter_profile_sample"
+
+// ProfileTable computes per-column statistics for the table selected by a Banquet path,
+// honoring its WHERE clause and the filter options. Statistics are exact unless the
+// table has more rows than opts.SampleSize, in which case a random sample is profiled.
+func (e *Engine) ProfileTable(ctx context.Context, path string, opts ProfileOptions) (*TableProfile, error) {
+	if opts.TopN <= 0 {
+		opts.TopN = DefaultProfileTopN
+	}
+	if opts.Bins <= 0 {
+		opts.Bins = DefaultProfileBins
+	}
+
+	pq, err := e.prepareQuery(ctx, QueryOptions{
+		BanquetPath:     path,
+		FilterWhere:     opts.FilterWhere,
+		FilterModelJSON: opts.FilterModelJSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	declared, err := declaredTypes(ctx, pq.db, pq.bq.Table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	columns := opts.Columns
+	if len(columns) == 0 {
+		for _, c := range declared {
+			columns = append(columns, c[0])
+		}
+	}
+	types := make(map[string]string)
+	for _, c := range declared {
+		types[c[0]] = c[1]
+	}
+	for _, c := range columns {
+		if _, ok := types[c]; !ok {
+			return nil, fmt.Errorf("no such column: %s", c)
+		}
+	}
+
+	profile := &TableProfile{Table: pq.bq.Table, Columns: make([]ColumnProfile, 0, len(columns))}
+	if err := pq.db.QueryRowContext(ctx, pq.countQuery).Scan(&profile.RowCount); err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	profile.SampleSize = profile.RowCount
+
+	source := sqlite.QuoteIdentifier(pq.bq.Table)
+	if pq.bq.Where != "" {
+		source = fmt.Sprintf("(SELECT * FROM %s WHERE %s)", source, pq.bq.Where)
+	}
+
+	db := pq.db
+	if opts.SampleSize > 0 && profile.RowCount > int64(opts.SampleSize) {
+		// The sample is written on a connection of its own, away from the
+		// cached one whose total_changes() is part of the data version keying
+		// the count, link and result caches, and which it would hold meanwhile
+		path, err := e.resolveDBPath(pq.dbRelPath)
+		if err != nil {
+			return nil, err
+		}
+		if db, err = openReadOnly(path); err != nil {
+			return nil, fmt.Errorf("error opening DB: %w", err)
+		}
+		defer db.Close()
+	}
+
+	// Pin one connection so the temp sample table stays visible to every query
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+	defer conn.Close()
+
+	if db != pq.db {
+		stmt := fmt.Sprintf("CREATE TEMP TABLE %s AS SELECT * FROM %s ORDER BY random() LIMIT %d",
+			profileSampleTable, source, opts.SampleSize)
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("error sampling table: %w", err)
+		}
+		source = profileSampleTable
+		profile.Sampled = true
+		profile.SampleSize = int64(opts.SampleSize)
+	}
+
+	for _, name := range columns {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cp, err := profileColumn(ctx, conn, source, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error profiling column %s: %w", name, err)
+		}
+		cp.DeclaredType = types[name]
+		profile.Columns = append(profile.Columns, *cp)
+	}
+	return profile, nil
+}
+
+func profileColumn(ctx context.Context, conn *sql.Conn, source, name string, opts ProfileOptions) (*ColumnProfile, error) {
+	col := sqlite.QuoteIdentifier(name)
+	cp := &ColumnProfile{Name: name, StorageClasses: make(map[string]int64), TopValues: make([]ValueCount, 0)}
+
+	// Storage classes actually present
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT typeof(%s), COUNT(*) FROM %s GROUP BY 1", col, source))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var class string
+		var n int64
+		if err := rows.Scan(&class, &n); err == nil {
+			cp.StorageClasses[class] = n
+		}
+	}
+	rows.Close()
+	cp.Nulls = cp.StorageClasses["null"]
+
+	err = conn.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT COALESCE(SUM(typeof(%s) = 'text' AND %s = ''), 0), COUNT(DISTINCT %s), MIN(%s), MAX(%s) FROM %s",
+		col, col, col, col, col, source)).Scan(&cp.Empty, &cp.DistinctEstimate, &cp.Min, &cp.Max)
+	if err != nil {
+		return nil, err
+	}
+	cp.Min = normalizeValue(cp.Min)
+	cp.Max = normalizeValue(cp.Max)
+
+	// Most frequent values
+	rows, err = conn.QueryContext(ctx, fmt.Sprintf("SELECT %s, COUNT(*) FROM %s GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT %d",
+		col, source, opts.TopN))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var vc ValueCount
+		if err := rows.Scan(&vc.Value, &vc.Count); err == nil {
+			vc.Value = normalizeValue(vc.Value)
+			cp.TopValues = append(cp.TopValues, vc)
+		}
+	}
+	rows.Close()
+
+	if cp.StorageClasses["integer"]+cp.StorageClasses["real"] == 0 {
+		return cp, nil
+	}
+
+	// Numeric moments and histogram, over numeric cells only
+	numeric := fmt.Sprintf("%s WHERE typeof(%s) IN ('integer', 'real')", source, col)
+	var mean, variance, lo, hi float64
+	err = conn.QueryRowContext(ctx, fmt.Sprintf("SELECT AVG(%s), MIN(%s), MAX(%s) FROM %s",
+		col, col, col, numeric)).Scan(&mean, &lo, &hi)
+	if err != nil {
+		return nil, err
+	}
+	// Deviations from the mean, as E[x²]-E[x]² cancels catastrophically for large values
+	err = conn.QueryRowContext(ctx, fmt.Sprintf("SELECT AVG((%s - ?) * (%s - ?)) FROM %s",
+		col, col, numeric), mean, mean).Scan(&variance)
+	if err != nil {
+		return nil, err
+	}
+	stddev := math.Sqrt(variance)
+	cp.Mean = &mean
+	cp.Stddev = &stddev
+
+	width := (hi - lo) / float64(opts.Bins)
+	if width == 0 {
+		cp.Histogram = []HistogramBin{{Low: lo, High: hi, Count: cp.StorageClasses["integer"] + cp.StorageClasses["real"]}}
+		return cp, nil
+	}
+
+	cp.Histogram = make([]HistogramBin, opts.Bins)
+	for i := range cp.Histogram {
+		cp.Histogram[i].Low = lo + float64(i)*width
+		cp.Histogram[i].High = lo + float64(i+1)*width
+	}
+	rows, err = conn.QueryContext(ctx, fmt.Sprintf("SELECT MIN(CAST((%s - ?) / ? AS INTEGER), ?), COUNT(*) FROM %s GROUP BY 1",
+		col, numeric), lo, width, opts.Bins-1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bin int
+		var n int64
+		if err := rows.Scan(&bin, &n); err == nil && bin >= 0 && bin < opts.Bins {
+			cp.Histogram[bin].Count = n
+		}
+	}
+	return cp, rows.Err()
+}
+
+// declaredTypes returns [name, declared type] pairs for the columns of a table.
+func declaredTypes(ctx context.Context, db *sql.DB, table string) ([][2]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols [][2]string
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err == nil {
+			cols = append(cols, [2]string{name, typ})
+		}
+	}
+	return cols, rows.Err()
+}
+
+// normalizeValue converts driver values for JSON output the same way Query does.
+func normalizeValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
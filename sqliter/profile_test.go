@@ -0,0 +1,117 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestProfileTable(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "imports.csv.db"), `
+		CREATE TABLE data (name TEXT, amount);
+		INSERT INTO data VALUES ('a', 1), ('b', 2), ('b', 3), ('', 4), (NULL, 'n/a'), ('c', NULL);
+		CREATE TABLE big (n INTEGER);
+		WITH RECURSIVE seq(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM seq WHERE x < 1000)
+		INSERT INTO big SELECT x FROM seq;
+		CREATE TABLE readings (v REAL);
+		INSERT INTO readings VALUES (1e9 + 1), (1e9 + 2), (1e9 + 3), (1e9 + 4);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	profile, err := engine.ProfileTable(ctx, "/imports.csv.db/data", ProfileOptions{Bins: 3})
+	if err != nil {
+		t.Fatalf("ProfileTable failed: %v", err)
+	}
+	if profile.RowCount != 6 || profile.Sampled || len(profile.Columns) != 2 {
+		t.Fatalf("Unexpected profile: %+v", profile)
+	}
+
+	name := profile.Columns[0]
+	if name.Nulls != 1 || name.Empty != 1 || name.DistinctEstimate != 4 {
+		t.Errorf("Unexpected name profile: %+v", name)
+	}
+	if name.TopValues[0].Value != "b" || name.TopValues[0].Count != 2 {
+		t.Errorf("Expected 'b' as top value, got %+v", name.TopValues)
+	}
+	if name.Mean != nil || name.Histogram != nil {
+		t.Errorf("Expected no numeric stats for text column, got %+v", name)
+	}
+
+	amount := profile.Columns[1]
+	if amount.StorageClasses["integer"] != 4 || amount.StorageClasses["text"] != 1 || amount.Nulls != 1 {
+		t.Errorf("Unexpected storage classes: %+v", amount.StorageClasses)
+	}
+	if amount.Mean == nil || *amount.Mean != 2.5 {
+		t.Errorf("Expected mean 2.5 over numeric cells, got %v", amount.Mean)
+	}
+	var binned int64
+	for _, b := range amount.Histogram {
+		binned += b.Count
+	}
+	if len(amount.Histogram) != 3 || binned != 4 {
+		t.Errorf("Expected 3 bins covering 4 numeric values, got %+v", amount.Histogram)
+	}
+
+	t.Run("LargeValues", func(t *testing.T) {
+		profile, err := engine.ProfileTable(ctx, "/imports.csv.db/readings", ProfileOptions{})
+		if err != nil {
+			t.Fatalf("ProfileTable failed: %v", err)
+		}
+		// Population standard deviation of 1..4 is sqrt(1.25)
+		sd := profile.Columns[0].Stddev
+		if sd == nil {
+			t.Fatalf("Expected a stddev")
+		}
+		if math.Abs(*sd-math.Sqrt(1.25)) > 1e-9 {
+			t.Errorf("Expected stddev %v, got %v", math.Sqrt(1.25), *sd)
+		}
+	})
+
+	t.Run("Sampling", func(t *testing.T) {
+		before, err := engine.DatabaseVersion(ctx, "imports.csv.db")
+		if err != nil {
+			t.Fatalf("DatabaseVersion failed: %v", err)
+		}
+		profile, err := engine.ProfileTable(ctx, "/imports.csv.db/big", ProfileOptions{SampleSize: 100})
+		if err != nil {
+			t.Fatalf("ProfileTable failed: %v", err)
+		}
+		if !profile.Sampled || profile.RowCount != 1000 || profile.Columns[0].StorageClasses["integer"] != 100 {
+			t.Errorf("Expected 100-row sample of 1000, got %+v", profile)
+		}
+		// The temp sample must not outlive the call
+		if _, err := engine.ProfileTable(ctx, "/imports.csv.db/big", ProfileOptions{SampleSize: 100}); err != nil {
+			t.Errorf("Second sampled profile failed: %v", err)
+		}
+		// Nor change the version that keys the caches
+		if after, _ := engine.DatabaseVersion(ctx, "imports.csv.db"); after != before {
+			t.Errorf("Expected sampling to keep the database version %q, got %q", before, after)
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/profile?path=/imports.csv.db/data&columns=amount", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body TableProfile
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(body.Columns) != 1 || body.Columns[0].Name != "amount" {
+			t.Errorf("Expected only the amount column, got %+v", body.Columns)
+		}
+	})
+}
@@ -0,0 +1,314 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// foreignKey is one (possibly composite) foreign key from pragma_foreign_key_list.
+type foreignKey struct {
+	Table string   // Child table declaring the key
+	From  []string // Child columns
+	Ref   string   // Parent table
+	To    []string // Parent columns; empty means the parent's primary key
+}
+
+// ColumnLink marks a result column whose values reference another table, so
+// clients can render cells as links to BanquetPath(DB, Table, Column = value).
+type ColumnLink struct {
+	Column       string `json:"column"`
+	DB           string `json:"db"`
+	Table        string `json:"table"`
+	TargetColumn string `json:"targetColumn"`
+}
+
+// RelatedLink points from a row to related rows in another table.
+type RelatedLink struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"` // Columns in Table that were matched
+	Path    string   `json:"path"`    // Banquet path selecting the related rows
+	Count   int64    `json:"count"`
+}
+
+// RelatedResult lists the rows a single row references and is referenced by.
+type RelatedResult struct {
+	Table    string        `json:"table"`
+	RowID    int64         `json:"rowid"`
+	Parents  []RelatedLink `json:"parents"`
+	Children []RelatedLink `json:"children"`
+}
+
+// tableForeignKeys returns the foreign keys declared by a table.
+func tableForeignKeys(ctx context.Context, db *sql.DB, table string) ([]foreignKey, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, "table", "from", "to" FROM pragma_foreign_key_list(?) ORDER BY id, seq`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []foreignKey
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var ref, from string
+		var to sql.NullString
+		if err := rows.Scan(&id, &ref, &from, &to); err != nil {
+			continue
+		}
+		if id != lastID {
+			fks = append(fks, foreignKey{Table: table, Ref: ref})
+			lastID = id
+		}
+		fk := &fks[len(fks)-1]
+		fk.From = append(fk.From, from)
+		if to.Valid {
+			fk.To = append(fk.To, to.String)
+		}
+	}
+	return fks, rows.Err()
+}
+
+// primaryKey returns the primary key columns of a table, or ["rowid"] if it has none.
+func primaryKey(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			cols = append(cols, name)
+		}
+	}
+	if len(cols) == 0 {
+		cols = []string{"rowid"}
+	}
+	return cols, rows.Err()
+}
+
+// referencedColumns resolves the parent columns of a foreign key, defaulting to the parent's primary key.
+func referencedColumns(ctx context.Context, db *sql.DB, fk foreignKey) ([]string, error) {
+	if len(fk.To) == len(fk.From) {
+		return fk.To, nil
+	}
+	return primaryKey(ctx, db, fk.Ref)
+}
+
+// tableExists reports whether a table or view exists. SQLite accepts foreign
+// keys that reference missing tables.
+func tableExists(ctx context.Context, db *sql.DB, name string) bool {
+	var n int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type IN ('table', 'view') AND name = ? COLLATE NOCASE", name).Scan(&n)
+	return err == nil && n > 0
+}
+
+// columnLinks returns link metadata for the single-column foreign keys of table.
+func columnLinks(ctx context.Context, db *sql.DB, dbRelPath, table string) ([]ColumnLink, error) {
+	fks, err := tableForeignKeys(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	var links []ColumnLink
+	for _, fk := range fks {
+		if len(fk.From) != 1 || !tableExists(ctx, db, fk.Ref) {
+			continue
+		}
+		to, err := referencedColumns(ctx, db, fk)
+		if err != nil || len(to) != 1 {
+			continue
+		}
+		links = append(links, ColumnLink{Column: fk.From[0], DB: dbRelPath, Table: fk.Ref, TargetColumn: to[0]})
+	}
+	return links, nil
+}
+
+// linkCache holds the column links of tables by database data version, as
+// reading foreign keys takes a pragma per key.
+type linkCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedLinks
+}
+
+type cachedLinks struct {
+	version string
+	links   []ColumnLink
+}
+
+func newLinkCache() *linkCache {
+	return &linkCache{entries: make(map[string]cachedLinks)}
+}
+
+func (c *linkCache) get(key, version string) ([]ColumnLink, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.version != version {
+		return nil, false
+	}
+	return entry.links, true
+}
+
+func (c *linkCache) put(key, version string, links []ColumnLink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedCounts {
+		c.entries = make(map[string]cachedLinks)
+	}
+	c.entries[key] = cachedLinks{version: version, links: links}
+}
+
+func (c *linkCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedLinks)
+}
+
+// tableLinks returns the column links of a table, cached until the database
+// changes. Links are best effort: errors yield none.
+func (e *Engine) tableLinks(ctx context.Context, db *sql.DB, dbRelPath, table string) []ColumnLink {
+	version, err := dataVersion(ctx, db)
+	if err != nil {
+		return nil
+	}
+	key := dbRelPath + "\x00" + table
+	if links, ok := e.links.get(key, version); ok {
+		return links
+	}
+	links, err := columnLinks(ctx, db, dbRelPath, table)
+	if err != nil {
+		return nil
+	}
+	e.links.put(key, version, links)
+	return links
+}
+
+// resultLinks returns the links of the columns present in a result.
+func resultLinks(links []ColumnLink, columns []string) []ColumnLink {
+	var out []ColumnLink
+	for _, l := range links {
+		if containsString(columns, l.Column) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Related follows the foreign keys of the table selected by a Banquet path, in both
+// directions, from the row with the given rowid. Parents are the rows it references;
+// children are the rows in other tables that reference it.
+func (e *Engine) Related(ctx context.Context, path string, rowid int64) (*RelatedResult, error) {
+	pq, err := e.prepareQuery(ctx, QueryOptions{BanquetPath: path})
+	if err != nil {
+		return nil, err
+	}
+	db, table := pq.db, pq.bq.Table
+
+	result := &RelatedResult{Table: table, RowID: rowid, Parents: make([]RelatedLink, 0), Children: make([]RelatedLink, 0)}
+
+	// Parents: the foreign keys this table declares, except dangling ones
+	fks, err := tableForeignKeys(ctx, db, table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	for _, fk := range fks {
+		if !tableExists(ctx, db, fk.Ref) {
+			continue
+		}
+		to, err := referencedColumns(ctx, db, fk)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		link, err := relatedLink(ctx, db, pq.dbRelPath, table, rowid, fk.From, fk.Ref, to)
+		if err != nil {
+			return nil, err
+		}
+		if link != nil {
+			result.Parents = append(result.Parents, *link)
+		}
+	}
+
+	// Children: foreign keys in any table that point at this one
+	tables, err := e.ListTables(ctx, pq.dbRelPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		if t.Type != "table" {
+			continue
+		}
+		childFKs, err := tableForeignKeys(ctx, db, t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		for _, fk := range childFKs {
+			if !strings.EqualFold(fk.Ref, table) {
+				continue
+			}
+			to, err := referencedColumns(ctx, db, fk)
+			if err != nil {
+				return nil, fmt.Errorf("database error: %w", err)
+			}
+			link, err := relatedLink(ctx, db, pq.dbRelPath, table, rowid, to, t.Name, fk.From)
+			if err != nil {
+				return nil, err
+			}
+			if link != nil {
+				result.Children = append(result.Children, *link)
+			}
+		}
+	}
+	return result, nil
+}
+
+// relatedLink reads srcCols of the source row and builds a link to the rows of
+// target whose dstCols hold the same values. It returns nil if any value is NULL.
+func relatedLink(ctx context.Context, db *sql.DB, dbRelPath, source string, rowid int64, srcCols []string, target string, dstCols []string) (*RelatedLink, error) {
+	if len(srcCols) != len(dstCols) {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(srcCols))
+	for i, c := range srcCols {
+		quoted[i] = sqlite.QuoteIdentifier(c)
+	}
+	values := make([]interface{}, len(srcCols))
+	ptrs := make([]interface{}, len(srcCols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE rowid = ?",
+		strings.Join(quoted, ", "), sqlite.QuoteIdentifier(source)), rowid).Scan(ptrs...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no row with rowid %d in %s", rowid, source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	conds := make([]string, len(dstCols))
+	for i, c := range dstCols {
+		if values[i] == nil {
+			return nil, nil
+		}
+		lit, err := sqlLiteral(values[i])
+		if err != nil {
+			return nil, err
+		}
+		conds[i] = fmt.Sprintf("%s = %s", sqlite.QuoteIdentifier(c), lit)
+	}
+	where := strings.Join(conds, " AND ")
+
+	link := &RelatedLink{Table: target, Columns: dstCols, Path: BanquetPath(dbRelPath, target, where)}
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", sqlite.QuoteIdentifier(target), where)).Scan(&link.Count); err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	return link, nil
+}
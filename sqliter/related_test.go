@@ -0,0 +1,146 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestRelated(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers, total REAL);
+		CREATE TABLE items (order_id INTEGER REFERENCES orders(id), sku TEXT);
+		INSERT INTO customers VALUES (1, 'Ada'), (2, 'Bob');
+		INSERT INTO orders VALUES (10, 1, 9.5), (11, 1, 3.0), (12, NULL, 1.0);
+		INSERT INTO items VALUES (10, 'a'), (10, 'b'), (11, 'c');
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	res, err := engine.Related(ctx, "/shop.db/orders", 10)
+	if err != nil {
+		t.Fatalf("Related failed: %v", err)
+	}
+	if len(res.Parents) != 1 || res.Parents[0].Table != "customers" || res.Parents[0].Count != 1 {
+		t.Fatalf("Expected the customer as parent, got %+v", res.Parents)
+	}
+	if len(res.Children) != 1 || res.Children[0].Table != "items" || res.Children[0].Count != 2 {
+		t.Fatalf("Expected 2 items as children, got %+v", res.Children)
+	}
+
+	// The parent path resolves to exactly the referenced customer
+	parent, err := engine.Query(ctx, QueryOptions{BanquetPath: res.Parents[0].Path})
+	if err != nil {
+		t.Fatalf("Query of parent path failed: %v", err)
+	}
+	if len(parent.Values) != 1 || parent.Values[0][1] != "Ada" {
+		t.Errorf("Unexpected parent rows for %s: %v", res.Parents[0].Path, parent.Values)
+	}
+
+	t.Run("Customer children", func(t *testing.T) {
+		res, err := engine.Related(ctx, "/shop.db/customers", 1)
+		if err != nil {
+			t.Fatalf("Related failed: %v", err)
+		}
+		if len(res.Parents) != 0 || len(res.Children) != 1 || res.Children[0].Count != 2 {
+			t.Errorf("Expected 2 orders for customer 1, got %+v", res)
+		}
+	})
+
+	t.Run("NULL foreign key has no parent", func(t *testing.T) {
+		res, err := engine.Related(ctx, "/shop.db/orders", 12)
+		if err != nil {
+			t.Fatalf("Related failed: %v", err)
+		}
+		if len(res.Parents) != 0 {
+			t.Errorf("Expected no parent, got %+v", res.Parents)
+		}
+	})
+
+	t.Run("Rows carry link metadata", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?path=/shop.db/orders", nil))
+		var body QueryResult
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(body.Links) != 1 || body.Links[0].Column != "customer_id" || body.Links[0].Table != "customers" || body.Links[0].TargetColumn != "id" {
+			t.Errorf("Unexpected links: %+v", body.Links)
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/related?path=/shop.db/orders&rowid=11", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestRelatedLinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "shop.db")
+	createTestDB(t, dbPath, `
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers, warehouse_id INTEGER REFERENCES warehouses);
+		INSERT INTO customers VALUES (1, 'Ada');
+		INSERT INTO orders VALUES (10, 1, 7);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	t.Run("Dangling foreign keys are skipped", func(t *testing.T) {
+		res, err := engine.Related(ctx, "/shop.db/orders", 10)
+		if err != nil {
+			t.Fatalf("Related failed: %v", err)
+		}
+		if len(res.Parents) != 1 || res.Parents[0].Table != "customers" {
+			t.Errorf("Expected only the customer as parent, got %+v", res.Parents)
+		}
+	})
+
+	t.Run("Streams carry link metadata", func(t *testing.T) {
+		var first QueryResultChunk
+		err := engine.QueryStream(ctx, QueryOptions{BanquetPath: "/shop.db/orders"}, func(chunk QueryResultChunk) error {
+			if chunk.Columns != nil {
+				first = chunk
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("QueryStream failed: %v", err)
+		}
+		if len(first.Links) == 0 || first.Links[0].Column != "customer_id" {
+			t.Errorf("Unexpected links: %+v", first.Links)
+		}
+	})
+
+	t.Run("Links follow schema changes", func(t *testing.T) {
+		res, err := engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/customers"})
+		if err != nil || len(res.Links) != 0 {
+			t.Fatalf("Expected no links, got %+v: %v", res, err)
+		}
+		createTestDB(t, dbPath, `
+			CREATE TABLE regions (id INTEGER PRIMARY KEY);
+			ALTER TABLE customers ADD COLUMN region_id INTEGER REFERENCES regions;
+		`)
+		res, err = engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/customers"})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(res.Links) != 1 || res.Links[0].Table != "regions" {
+			t.Errorf("Expected a link to regions, got %+v", res.Links)
+		}
+	})
+}
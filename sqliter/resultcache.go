@@ -0,0 +1,194 @@
+package sqliter
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CacheStats reports the result cache's effectiveness and size.
+type CacheStats struct {
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+	Entries  int   `json:"entries"`
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"maxBytes"`
+}
+
+type cachedResult struct {
+	key      string
+	version  string
+	result   *QueryResult // Without Values when stored by QueryJSON
+	rows     []byte       // The JSON encoding of the rows, when stored by QueryJSON
+	rowCount int64
+	size     int64
+}
+
+// resultCache is an LRU cache of query results bounded by their approximate size.
+type resultCache struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List // Front is most recently used
+	bytes    int64
+	maxBytes int64
+	hits     int64
+	misses   int64
+}
+
+func newResultCache(maxBytes int64) *resultCache {
+	return &resultCache{entries: make(map[string]*list.Element), lru: list.New(), maxBytes: maxBytes}
+}
+
+// get returns the entry stored under key if it was stored at version.
+func (c *resultCache) get(key, version string) (*cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := el.Value.(*cachedResult)
+	if entry.version != version {
+		c.remove(el)
+		c.misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	c.hits++
+	return entry, true
+}
+
+// put stores an entry, evicting the least recently used ones beyond maxBytes.
+// Entries larger than a quarter of the cache are not stored.
+func (c *resultCache) put(key, version string, entry *cachedResult) {
+	entry.key, entry.version = key, version
+	entry.size = resultSize(entry.result) + int64(len(entry.rows))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry.size > c.maxBytes/4 {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *resultCache) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*cachedResult)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+func (c *resultCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+func (c *resultCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.lru.Len(), Bytes: c.bytes, MaxBytes: c.maxBytes}
+}
+
+// resultSize approximates the memory held by a result.
+func resultSize(res *QueryResult) int64 {
+	size := int64(len(res.SQL)) + 64
+	for _, c := range res.Columns {
+		size += int64(len(c)) + 16
+	}
+	for _, row := range res.Values {
+		size += rowSize(row)
+	}
+	return size
+}
+
+// rowSize estimates the memory held by one result row.
+func rowSize(row []interface{}) int64 {
+	size := int64(24)
+	for _, v := range row {
+		size += 16
+		switch v := v.(type) {
+		case string:
+			size += int64(len(v))
+		case []byte:
+			size += int64(len(v))
+		case TypedValue:
+			size += 48
+			if s, ok := v.Value.(string); ok {
+				size += int64(len(s))
+			}
+		}
+	}
+	return size
+}
+
+// CacheStats returns the result cache statistics; all zero when the cache is disabled.
+func (e *Engine) CacheStats() CacheStats {
+	if e.results == nil {
+		return CacheStats{}
+	}
+	return e.results.stats()
+}
+
+// ClearCache empties the result, count and link caches.
+func (e *Engine) ClearCache() {
+	if e.results != nil {
+		e.results.clear()
+	}
+	e.counts.clear()
+	e.links.clear()
+}
+
+// DatabaseVersion identifies the current state of a database: its connection's
+// data version and the file's size and modification time, including the WAL's.
+// It changes whenever the database is written, by this engine or anyone else.
+func (e *Engine) DatabaseVersion(ctx context.Context, dbRelPath string) (string, error) {
+	dbRelPath = strings.TrimPrefix(dbRelPath, "/")
+	path, err := e.resolveDBPath(dbRelPath)
+	if err != nil {
+		return "", err
+	}
+	// Opening a missing file would create it
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("database not found: %s", dbRelPath)
+	}
+	db, err := e.openDB(ctx, dbRelPath)
+	if err != nil {
+		return "", err
+	}
+	version, err := dataVersion(ctx, db)
+	if err != nil {
+		return "", fmt.Errorf("database error: %w", err)
+	}
+	for _, p := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(p); err == nil {
+			version += fmt.Sprintf(":%d:%d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return version, nil
+}
+
+// resultCacheKey identifies a query's result: the database, the composed page
+// and count queries and the count strategy.
+func resultCacheKey(pq *preparedQuery, opts QueryOptions) string {
+	key := pq.dbRelPath + "\x00" + pq.query
+	if !opts.SkipTotalCount {
+		key += "\x00" + pq.countQuery + "\x00" + opts.CountStrategy
+	}
+	if opts.TypedValues {
+		key += "\x00typed"
+	}
+	return key
+}
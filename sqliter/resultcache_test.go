@@ -0,0 +1,137 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestResultCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "shop.db")
+	createTestDB(t, dbPath, `
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT);
+		INSERT INTO orders (customer) VALUES ('ada'), ('bob'), ('cy');
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir, Writable: true, ResultCacheBytes: 1 << 20})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	page := func(t *testing.T, offset int) *QueryResult {
+		t.Helper()
+		res, err := engine.Query(ctx, QueryOptions{BanquetPath: "/shop.db/orders", AllowOverride: true, Limit: 2, Offset: offset})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		return res
+	}
+
+	if res := page(t, 0); res.Cached || len(res.Values) != 2 {
+		t.Fatalf("Expected an uncached first page, got %+v", res)
+	}
+	if res := page(t, 2); res.Cached || len(res.Values) != 1 {
+		t.Fatalf("Expected an uncached second page, got %+v", res)
+	}
+	res := page(t, 0)
+	if !res.Cached || len(res.Values) != 2 || res.TotalCount != 3 {
+		t.Errorf("Expected the first page from the cache, got %+v", res)
+	}
+	// Cached rows are copied, so changing a result cannot corrupt the cache
+	res.Values[0][1] = "changed"
+	if again := page(t, 0); again.Values[0][1] != "ada" {
+		t.Errorf("Expected the cached page to be unchanged, got %+v", again.Values)
+	}
+	if stats := engine.CacheStats(); stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 || stats.Bytes == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// A write by another connection changes data_version and the file
+	createTestDB(t, dbPath, "UPDATE orders SET customer = 'ann' WHERE id = 1")
+	if res := page(t, 0); res.Cached || res.Values[0][1] != "ann" {
+		t.Errorf("Expected a fresh page after an external write, got %+v", res)
+	}
+
+	// So does a write through the engine's own connection
+	if _, err := engine.ExecuteSQL(ctx, SQLOptions{DB: "shop.db", SQL: "DELETE FROM orders WHERE id = 3"}); err != nil {
+		t.Fatalf("ExecuteSQL failed: %v", err)
+	}
+	page(t, 0)
+	if res := page(t, 2); res.Cached || len(res.Values) != 0 {
+		t.Errorf("Expected a fresh page after an own write, got %+v", res)
+	}
+
+	engine.ClearCache()
+	if stats := engine.CacheStats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Expected an empty cache, got %+v", stats)
+	}
+}
+
+func TestResultCacheEviction(t *testing.T) {
+	c := newResultCache(4000)
+	res := &QueryResult{Columns: []string{"v"}, Values: [][]interface{}{{string(make([]byte, 800))}}}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		c.put(key, "1", &cachedResult{result: res})
+	}
+	if _, ok := c.get("a", "1"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := c.get("e", "1"); !ok {
+		t.Error("Expected the latest entry to be cached")
+	}
+	if _, ok := c.get("e", "2"); ok {
+		t.Error("Expected a version mismatch to miss")
+	}
+	if c.bytes > c.maxBytes {
+		t.Errorf("Cache exceeds its bound: %d > %d", c.bytes, c.maxBytes)
+	}
+
+	c.put("big", "1", &cachedResult{result: &QueryResult{Values: [][]interface{}{{string(make([]byte, 2000))}}}})
+	if _, ok := c.get("big", "1"); ok {
+		t.Error("Expected an oversized result not to be cached")
+	}
+}
+
+func TestAPICache(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `CREATE TABLE orders (id INTEGER PRIMARY KEY);`)
+
+	srv := NewServer(&Config{ServeFolder: tmpDir, ResultCacheBytes: 1 << 20})
+	defer srv.engine.CloseAll()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?path=/shop.db/orders", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/cache", nil))
+	var stats CacheStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", stats)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("DELETE", "/sqliter/cache", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 on a read-only server, got %d", w.Code)
+	}
+
+	srv.config.Writable = true
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("DELETE", "/sqliter/cache", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil || stats.Entries != 0 {
+		t.Errorf("Expected an emptied cache, got %+v (%v)", stats, err)
+	}
+}
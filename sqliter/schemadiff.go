@@ -0,0 +1,323 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// SchemaChange is one structural difference between two databases.
+type SchemaChange struct {
+	Kind   string `json:"kind"`            // table, column, index, view or trigger
+	Name   string `json:"name"`            // Object or column name
+	Table  string `json:"table,omitempty"` // Owning table of columns, indexes and triggers
+	Change string `json:"change"`          // added, removed or altered
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// SchemaDiff lists the changes that turn database A into database B.
+type SchemaDiff struct {
+	A         string         `json:"a"`
+	B         string         `json:"b"`
+	Changes   []SchemaChange `json:"changes"`
+	Migration string         `json:"migration,omitempty"`
+}
+
+// DiffSchemaOptions controls DiffSchema.
+type DiffSchemaOptions struct {
+	Migration bool // Also produce a SQL script that migrates A to B
+}
+
+// schemaObject is one entry of sqlite_master.
+type schemaObject struct {
+	Type  string
+	Name  string
+	Table string
+	SQL   string
+}
+
+// readSchemaObjects returns the user-defined objects of a database keyed by type, then name.
+// Automatic indexes (which have no SQL) and sqlite_ internals are skipped.
+func readSchemaObjects(ctx context.Context, db *sql.DB) (map[string]map[string]schemaObject, error) {
+	rows, err := db.QueryContext(ctx, "SELECT type, name, tbl_name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objects := map[string]map[string]schemaObject{"table": {}, "index": {}, "view": {}, "trigger": {}}
+	for rows.Next() {
+		var o schemaObject
+		if err := rows.Scan(&o.Type, &o.Name, &o.Table, &o.SQL); err != nil {
+			continue
+		}
+		if objects[o.Type] != nil {
+			objects[o.Type][o.Name] = o
+		}
+	}
+	return objects, rows.Err()
+}
+
+// normalizeSQL collapses whitespace so formatting-only differences are ignored.
+func normalizeSQL(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// columnDef renders a column the way it would be declared.
+func columnDef(c GraphColumn) string {
+	def := sqlite.QuoteIdentifier(c.Name)
+	if c.Type != "" {
+		def += " " + c.Type
+	}
+	if c.NotNull {
+		def += " NOT NULL"
+	}
+	if c.Default != nil {
+		def += " DEFAULT " + *c.Default
+	}
+	if c.PrimaryKey > 0 {
+		def += fmt.Sprintf(" PRIMARY KEY (%d)", c.PrimaryKey)
+	}
+	return def
+}
+
+func sortedNames(m map[string]schemaObject) []string {
+	names := make([]string, 0, len(m))
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DiffSchema compares the schemas of two databases, both relative to ServeFolder,
+// and reports the tables, columns, indexes, views and triggers that were added,
+// removed or altered going from dbA to dbB.
+func (e *Engine) DiffSchema(ctx context.Context, dbA, dbB string, opts DiffSchemaOptions) (*SchemaDiff, error) {
+	dbs := make([]*sql.DB, 2)
+	for i, rel := range []string{dbA, dbB} {
+		path, err := e.resolveDBPath(rel)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("database not found: %s", rel)
+		}
+		if dbs[i], err = e.openDB(ctx, rel); err != nil {
+			return nil, err
+		}
+	}
+	return diffSchema(ctx, dbs[0], dbs[1], dbA, dbB, opts)
+}
+
+// DiffSchemaFiles is DiffSchema for two database files outside any served
+// folder. They are opened read-only, leaving their journal mode untouched.
+func DiffSchemaFiles(ctx context.Context, pathA, pathB string, opts DiffSchemaOptions) (*SchemaDiff, error) {
+	dbs := make([]*sql.DB, 2)
+	for i, path := range []string{pathA, pathB} {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("database not found: %s", path)
+		}
+		db, err := openReadOnly(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening DB: %w", err)
+		}
+		defer db.Close()
+		dbs[i] = db
+	}
+	return diffSchema(ctx, dbs[0], dbs[1], pathA, pathB, opts)
+}
+
+func diffSchema(ctx context.Context, dbA, dbB *sql.DB, nameA, nameB string, opts DiffSchemaOptions) (*SchemaDiff, error) {
+	dbs := []*sql.DB{dbA, dbB}
+	objects := make([]map[string]map[string]schemaObject, 2)
+	for i, db := range dbs {
+		var err error
+		if objects[i], err = readSchemaObjects(ctx, db); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+	}
+	a, b := objects[0], objects[1]
+
+	diff := &SchemaDiff{A: nameA, B: nameB, Changes: make([]SchemaChange, 0)}
+	rebuilt := make(map[string]bool) // Tables present in both whose definition changed
+
+	for _, kind := range []string{"table", "view", "index", "trigger"} {
+		for _, name := range sortedNames(a[kind]) {
+			if _, ok := b[kind][name]; !ok {
+				diff.Changes = append(diff.Changes, SchemaChange{Kind: kind, Name: name, Table: ownerTable(a[kind][name]), Change: "removed", Before: a[kind][name].SQL})
+			}
+		}
+		for _, name := range sortedNames(b[kind]) {
+			objB := b[kind][name]
+			objA, ok := a[kind][name]
+			if !ok {
+				diff.Changes = append(diff.Changes, SchemaChange{Kind: kind, Name: name, Table: ownerTable(objB), Change: "added", After: objB.SQL})
+				continue
+			}
+			if normalizeSQL(objA.SQL) == normalizeSQL(objB.SQL) {
+				continue
+			}
+			diff.Changes = append(diff.Changes, SchemaChange{Kind: kind, Name: name, Table: ownerTable(objB), Change: "altered", Before: objA.SQL, After: objB.SQL})
+			if kind == "table" {
+				rebuilt[name] = true
+				changes, err := diffColumns(ctx, dbs[0], dbs[1], name)
+				if err != nil {
+					return nil, fmt.Errorf("database error: %w", err)
+				}
+				diff.Changes = append(diff.Changes, changes...)
+			}
+		}
+	}
+
+	if opts.Migration && len(diff.Changes) > 0 {
+		migration, err := migrationScript(ctx, dbs[0], dbs[1], a, b, rebuilt)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		diff.Migration = migration
+	}
+	return diff, nil
+}
+
+// ownerTable returns the table an index or trigger belongs to.
+func ownerTable(o schemaObject) string {
+	if o.Type == "index" || o.Type == "trigger" {
+		return o.Table
+	}
+	return ""
+}
+
+// diffColumns reports the column changes of a table present in both databases.
+func diffColumns(ctx context.Context, dbA, dbB *sql.DB, table string) ([]SchemaChange, error) {
+	colsA, err := tableColumnInfo(ctx, dbA, table)
+	if err != nil {
+		return nil, err
+	}
+	colsB, err := tableColumnInfo(ctx, dbB, table)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]GraphColumn)
+	for _, c := range colsB {
+		byName[strings.ToLower(c.Name)] = c
+	}
+
+	var changes []SchemaChange
+	seen := make(map[string]bool)
+	for _, ca := range colsA {
+		key := strings.ToLower(ca.Name)
+		seen[key] = true
+		cb, ok := byName[key]
+		if !ok {
+			changes = append(changes, SchemaChange{Kind: "column", Name: ca.Name, Table: table, Change: "removed", Before: columnDef(ca)})
+		} else if columnDef(ca) != columnDef(cb) {
+			changes = append(changes, SchemaChange{Kind: "column", Name: ca.Name, Table: table, Change: "altered", Before: columnDef(ca), After: columnDef(cb)})
+		}
+	}
+	for _, cb := range colsB {
+		if !seen[strings.ToLower(cb.Name)] {
+			changes = append(changes, SchemaChange{Kind: "column", Name: cb.Name, Table: table, Change: "added", After: columnDef(cb)})
+		}
+	}
+	return changes, nil
+}
+
+// migrationScript builds SQL that turns schema a into schema b. Altered tables are
+// rebuilt (rename, create, copy shared columns, drop) since SQLite's ALTER TABLE
+// cannot change most of a table definition; their indexes and triggers are recreated.
+// legacy_alter_table keeps the rename from rewriting references in other objects.
+func migrationScript(ctx context.Context, dbA, dbB *sql.DB, a, b map[string]map[string]schemaObject, rebuilt map[string]bool) (string, error) {
+	var drops, creates []string
+
+	// Drop dependants first: triggers, views and indexes that are gone or changed
+	for _, kind := range []string{"trigger", "view", "index"} {
+		for _, name := range sortedNames(a[kind]) {
+			objB, ok := b[kind][name]
+			if ok && normalizeSQL(objB.SQL) == normalizeSQL(a[kind][name].SQL) && !rebuilt[objB.Table] {
+				continue
+			}
+			if ok && rebuilt[objB.Table] && kind != "view" {
+				continue // Dropped along with the rebuilt table
+			}
+			drops = append(drops, fmt.Sprintf("DROP %s IF EXISTS %s;", strings.ToUpper(kind), sqlite.QuoteIdentifier(name)))
+		}
+	}
+
+	for _, name := range sortedNames(a["table"]) {
+		if _, ok := b["table"][name]; !ok {
+			drops = append(drops, fmt.Sprintf("DROP TABLE IF EXISTS %s;", sqlite.QuoteIdentifier(name)))
+		}
+	}
+
+	for _, name := range sortedNames(b["table"]) {
+		objB := b["table"][name]
+		if _, ok := a["table"][name]; !ok {
+			creates = append(creates, objB.SQL+";")
+			continue
+		}
+		if !rebuilt[name] {
+			continue
+		}
+		shared, err := sharedColumns(ctx, dbA, dbB, name)
+		if err != nil {
+			return "", err
+		}
+		old := sqlite.QuoteIdentifier("sqliter_old_" + name)
+		creates = append(creates,
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", sqlite.QuoteIdentifier(name), old),
+			objB.SQL+";")
+		if len(shared) > 0 {
+			cols := strings.Join(shared, ", ")
+			creates = append(creates, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s;", sqlite.QuoteIdentifier(name), cols, cols, old))
+		}
+		creates = append(creates, fmt.Sprintf("DROP TABLE %s;", old))
+	}
+
+	for _, kind := range []string{"index", "view", "trigger"} {
+		for _, name := range sortedNames(b[kind]) {
+			objB := b[kind][name]
+			objA, ok := a[kind][name]
+			if ok && normalizeSQL(objA.SQL) == normalizeSQL(objB.SQL) && !rebuilt[objB.Table] {
+				continue
+			}
+			creates = append(creates, objB.SQL+";")
+		}
+	}
+
+	var script strings.Builder
+	script.WriteString("PRAGMA foreign_keys = OFF;\nPRAGMA legacy_alter_table = ON;\nBEGIN;\n")
+	for _, stmt := range append(drops, creates...) {
+		script.WriteString(stmt + "\n")
+	}
+	script.WriteString("COMMIT;\nPRAGMA legacy_alter_table = OFF;\nPRAGMA foreign_keys = ON;\n")
+	return script.String(), nil
+}
+
+// sharedColumns returns the quoted columns a table has in both databases.
+func sharedColumns(ctx context.Context, dbA, dbB *sql.DB, table string) ([]string, error) {
+	colsA, err := tableColumns(ctx, dbA, table)
+	if err != nil {
+		return nil, err
+	}
+	colsB, err := tableColumns(ctx, dbB, table)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	for _, c := range colsB {
+		for _, ca := range colsA {
+			if strings.EqualFold(c, ca) {
+				shared = append(shared, sqlite.QuoteIdentifier(c))
+				break
+			}
+		}
+	}
+	return shared, nil
+}
@@ -0,0 +1,140 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestDiffSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "v1.xlsx.db"), `
+		CREATE TABLE sheet1 (id INTEGER PRIMARY KEY, name TEXT, price TEXT);
+		CREATE TABLE old_notes (body TEXT);
+		CREATE INDEX sheet1_name ON sheet1(name);
+		CREATE VIEW cheap AS SELECT * FROM sheet1 WHERE price < 10;
+		INSERT INTO sheet1 VALUES (1, 'apple', '3'), (2, 'pear', '12');
+	`)
+	createTestDB(t, filepath.Join(tmpDir, "v2.xlsx.db"), `
+		CREATE TABLE sheet1 (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '', price REAL, sku TEXT);
+		CREATE TABLE sheet2 (x);
+		CREATE INDEX sheet1_name ON sheet1(name);
+		CREATE VIEW cheap AS SELECT * FROM sheet1 WHERE price < 5;
+		CREATE TRIGGER sheet1_sku AFTER INSERT ON sheet1 BEGIN UPDATE sheet1 SET sku = 'x' WHERE id = new.id; END;
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	diff, err := engine.DiffSchema(ctx, "v1.xlsx.db", "v2.xlsx.db", DiffSchemaOptions{Migration: true})
+	if err != nil {
+		t.Fatalf("DiffSchema failed: %v", err)
+	}
+	got := make(map[string]string)
+	for _, c := range diff.Changes {
+		got[c.Kind+" "+c.Table+"."+c.Name] = c.Change
+	}
+	want := map[string]string{
+		"table .old_notes":          "removed",
+		"table .sheet2":             "added",
+		"table .sheet1":             "altered",
+		"column sheet1.name":        "altered",
+		"column sheet1.price":       "altered",
+		"column sheet1.sku":         "added",
+		"view .cheap":               "altered",
+		"trigger sheet1.sheet1_sku": "added",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Expected %s to be %s, got %q", k, v, got[k])
+		}
+	}
+	if len(diff.Changes) != len(want) {
+		t.Errorf("Expected %d changes, got %+v", len(want), diff.Changes)
+	}
+
+	t.Run("Migration", func(t *testing.T) {
+		db, err := engine.openDB(ctx, "v1.xlsx.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.ExecContext(ctx, diff.Migration); err != nil {
+			t.Fatalf("Migration failed: %v\n%s", err, diff.Migration)
+		}
+		after, err := engine.DiffSchema(ctx, "v1.xlsx.db", "v2.xlsx.db", DiffSchemaOptions{})
+		if err != nil {
+			t.Fatalf("DiffSchema failed: %v", err)
+		}
+		if len(after.Changes) != 0 {
+			t.Errorf("Expected no changes after migrating, got %+v", after.Changes)
+		}
+		var n int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sheet1 WHERE name = 'pear' AND price = 12").Scan(&n); err != nil || n != 1 {
+			t.Errorf("Expected rows to survive the rebuild, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/diff/schema?a=v2.xlsx.db&b=v2.xlsx.db", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body SchemaDiff
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(body.Changes) != 0 || body.Migration != "" {
+			t.Errorf("Expected an empty diff, got %+v", body)
+		}
+
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/diff/schema?a=v2.xlsx.db&b=missing.db", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500 for a missing database, got %d", w.Code)
+		}
+	})
+}
+
+func TestDiffSchemaFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	a, b := filepath.Join(tmpDir, "a.db"), filepath.Join(tmpDir, "b.db")
+	createTestDB(t, a, "CREATE TABLE t (x)")
+	createTestDB(t, b, "CREATE TABLE t (x, y)")
+
+	diff, err := DiffSchemaFiles(context.Background(), a, b, DiffSchemaOptions{Migration: true})
+	if err != nil {
+		t.Fatalf("DiffSchemaFiles failed: %v", err)
+	}
+	if len(diff.Changes) != 2 || diff.Migration == "" {
+		t.Errorf("Expected the table and column changes, got %+v", diff)
+	}
+	// The files are only read: neither is switched to WAL
+	for _, p := range []string{a, b} {
+		if _, err := os.Stat(p + "-wal"); err == nil {
+			t.Errorf("Expected no WAL file next to %s", p)
+		}
+		db, err := sql.Open("sqlite", p)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		var mode string
+		db.QueryRow("PRAGMA journal_mode").Scan(&mode)
+		db.Close()
+		if mode != "delete" {
+			t.Errorf("Expected %s to keep its rollback journal, got %q", p, mode)
+		}
+	}
+	if _, err := DiffSchemaFiles(context.Background(), a, filepath.Join(tmpDir, "missing.db"), DiffSchemaOptions{}); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,225 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// DefaultSearchLimitPerTable caps the rowids returned for a single table by SearchDatabase.
+const DefaultSearchLimitPerTable = 50
+
+// SearchDatabaseOptions controls a value search across the tables of one database.
+type SearchDatabaseOptions struct {
+	DB            string        // Database path relative to ServeFolder
+	Query         string        // Text to look for
+	Tables        []string      // Restrict the search to these tables; empty means all
+	LimitPerTable int           // Maximum rowids per table; <= 0 uses DefaultSearchLimitPerTable
+	TableTimeout  time.Duration // Abandon a single table's scan after this long; 0 means no limit
+}
+
+// SearchHit lists the rows of one table that contain the search text.
+type SearchHit struct {
+	Table     string   `json:"table"`
+	Path      string   `json:"path"` // Banquet path selecting exactly the matching rows
+	RowIDs    []int64  `json:"rowids"`
+	Columns   []string `json:"columns"` // Columns that were searched
+	Truncated bool     `json:"truncated,omitempty"`
+	FTS       bool     `json:"fts,omitempty"` // True if an FTS5 index answered the search
+	Error     string   `json:"error,omitempty"`
+}
+
+// SearchDatabaseResult is the outcome of SearchDatabase.
+type SearchDatabaseResult struct {
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// SearchDatabase scans the text columns of every table in a database for a value.
+// Tables with an FTS5 index are searched through it; all others fall back to LIKE.
+// Per-table failures and timeouts are reported on the hit instead of aborting the search.
+func (e *Engine) SearchDatabase(ctx context.Context, opts SearchDatabaseOptions) (*SearchDatabaseResult, error) {
+	opts.Query = strings.TrimSpace(opts.Query)
+	if opts.Query == "" {
+		return nil, fmt.Errorf("search query required")
+	}
+	if opts.LimitPerTable <= 0 {
+		opts.LimitPerTable = DefaultSearchLimitPerTable
+	}
+
+	dbPath, err := e.resolveDBPath(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := e.ListTables(ctx, opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := e.getDBConnection(ctx, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening DB: %w", err)
+	}
+
+	virtual, err := virtualTables(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	only := make(map[string]bool)
+	for _, t := range opts.Tables {
+		only[t] = true
+	}
+
+	result := &SearchDatabaseResult{Query: opts.Query, Hits: make([]SearchHit, 0)}
+	for _, tbl := range tables {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Views have no stable rowid to link back to
+		if tbl.Type != "table" || isInternalTable(tbl.Name, virtual) {
+			continue
+		}
+		if len(only) > 0 && !only[tbl.Name] {
+			continue
+		}
+
+		hit := e.searchTable(ctx, db, opts, tbl.Name)
+		if len(hit.RowIDs) > 0 || hit.Error != "" {
+			result.Hits = append(result.Hits, hit)
+		}
+	}
+	return result, nil
+}
+
+func (e *Engine) searchTable(ctx context.Context, db *sql.DB, opts SearchDatabaseOptions, table string) SearchHit {
+	hit := SearchHit{Table: table}
+
+	if opts.TableTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.TableTimeout)
+		defer cancel()
+	}
+
+	var query string
+	var args []interface{}
+
+	fts, err := findFTSIndex(ctx, db, table)
+	if err != nil {
+		hit.Error = err.Error()
+		return hit
+	}
+
+	if fts != nil {
+		hit.FTS = true
+		hit.Columns = fts.Columns
+		query = fmt.Sprintf("SELECT rowid FROM %s WHERE %s MATCH ? ORDER BY rank LIMIT %d",
+			sqlite.QuoteIdentifier(fts.Name), sqlite.QuoteIdentifier(fts.Name), opts.LimitPerTable+1)
+		args = append(args, ftsPhrase(opts.Query))
+	} else {
+		hit.Columns, err = textColumns(ctx, db, table)
+		if err != nil {
+			hit.Error = err.Error()
+			return hit
+		}
+		if len(hit.Columns) == 0 {
+			return hit
+		}
+		pattern := "%" + escapeLike(opts.Query) + "%"
+		conds := make([]string, len(hit.Columns))
+		for i, col := range hit.Columns {
+			conds[i] = fmt.Sprintf("%s LIKE ? ESCAPE '\\'", sqlite.QuoteIdentifier(col))
+			args = append(args, pattern)
+		}
+		query = fmt.Sprintf("SELECT rowid FROM %s WHERE %s LIMIT %d",
+			sqlite.QuoteIdentifier(table), strings.Join(conds, " OR "), opts.LimitPerTable+1)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		hit.Error = err.Error()
+		return hit
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rowid int64
+		if err := rows.Scan(&rowid); err != nil {
+			continue
+		}
+		hit.RowIDs = append(hit.RowIDs, rowid)
+	}
+	if err := rows.Err(); err != nil {
+		hit.Error = err.Error()
+	}
+
+	if len(hit.RowIDs) > opts.LimitPerTable {
+		hit.RowIDs = hit.RowIDs[:opts.LimitPerTable]
+		hit.Truncated = true
+	}
+	if len(hit.RowIDs) > 0 {
+		hit.Path = BanquetPath(opts.DB, table, "rowid IN ("+joinInt64(hit.RowIDs)+")")
+	}
+	return hit
+}
+
+// textColumns returns the columns of a table that can hold text: those with TEXT
+// affinity and those with no declared type, which is common in CSV imports.
+func textColumns(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			continue
+		}
+		t := strings.ToUpper(typ)
+		if t == "" || strings.Contains(t, "CHAR") || strings.Contains(t, "CLOB") || strings.Contains(t, "TEXT") {
+			cols = append(cols, name)
+		}
+	}
+	return cols, rows.Err()
+}
+
+// tableColumns returns the column names of a table in declaration order.
+func tableColumns(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			cols = append(cols, name)
+		}
+	}
+	return cols, rows.Err()
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally with ESCAPE '\'.
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+func joinInt64(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%d", id)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,92 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSearchDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "shop.db"), `
+		CREATE TABLE invoices (id INTEGER PRIMARY KEY, number TEXT, amount REAL);
+		INSERT INTO invoices (number, amount) VALUES ('INV-20390', 10), ('INV-20391', 20), ('INV-20392', 30);
+		CREATE TABLE notes (body TEXT);
+		INSERT INTO notes VALUES ('paid INV-20391 late'), ('nothing here');
+		CREATE TABLE docs (title TEXT);
+		INSERT INTO docs VALUES ('about INV-20391'), ('other');
+		CREATE VIRTUAL TABLE docs_fts USING fts5(title, content='docs', content_rowid='rowid');
+		INSERT INTO docs_fts(docs_fts) VALUES ('rebuild');
+		CREATE VIEW v_invoices AS SELECT * FROM invoices;
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+
+	res, err := engine.SearchDatabase(context.Background(), SearchDatabaseOptions{DB: "shop.db", Query: "INV-20391"})
+	if err != nil {
+		t.Fatalf("SearchDatabase failed: %v", err)
+	}
+
+	hits := make(map[string]SearchHit)
+	for _, h := range res.Hits {
+		hits[h.Table] = h
+	}
+	if len(hits) != 3 {
+		t.Fatalf("Expected hits in invoices, notes and docs only, got %+v", res.Hits)
+	}
+	if h := hits["invoices"]; len(h.RowIDs) != 1 || h.RowIDs[0] != 2 || h.FTS {
+		t.Errorf("Unexpected invoices hit: %+v", h)
+	}
+	if h := hits["docs"]; !h.FTS || len(h.RowIDs) != 1 || h.RowIDs[0] != 1 {
+		t.Errorf("Expected FTS hit on docs row 1, got %+v", h)
+	}
+
+	// The returned path must select exactly the matching rows
+	qr, err := engine.Query(context.Background(), QueryOptions{BanquetPath: hits["notes"].Path})
+	if err != nil {
+		t.Fatalf("Query of hit path failed: %v", err)
+	}
+	if len(qr.Values) != 1 || qr.Values[0][0] != "paid INV-20391 late" {
+		t.Errorf("Unexpected rows for hit path %s: %v", hits["notes"].Path, qr.Values)
+	}
+
+	t.Run("Per-table limit", func(t *testing.T) {
+		res, err := engine.SearchDatabase(context.Background(), SearchDatabaseOptions{DB: "shop.db", Query: "INV-2039", Tables: []string{"invoices"}, LimitPerTable: 2})
+		if err != nil {
+			t.Fatalf("SearchDatabase failed: %v", err)
+		}
+		if len(res.Hits) != 1 || len(res.Hits[0].RowIDs) != 2 || !res.Hits[0].Truncated {
+			t.Errorf("Expected 2 truncated rowids, got %+v", res.Hits)
+		}
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := engine.SearchDatabase(ctx, SearchDatabaseOptions{DB: "shop.db", Query: "x"}); err == nil {
+			t.Errorf("Expected error for cancelled context")
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/search?db=shop.db&q=nothing", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body SearchDatabaseResult
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(body.Hits) != 1 || body.Hits[0].Table != "notes" {
+			t.Errorf("Expected one hit in notes, got %+v", body.Hits)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -14,6 +15,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -24,6 +27,9 @@ var uiFS embed.FS
 type Server struct {
 	config *Config
 	engine *Engine
+
+	compressOnce sync.Once
+	compressors  *compressorPools
 }
 
 func NewServer(cfg *Config) *Server {
@@ -35,6 +41,9 @@ func NewServer(cfg *Config) *Server {
 
 // ServeHTTP implements http.Handler.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w, done := s.withCompression(w, r)
+	defer done()
+
 	// 1. API Handling
 	if strings.HasPrefix(r.URL.Path, "/sqliter/") {
 		s.handleAPI(w, r)
@@ -132,6 +141,9 @@ func (s *Server) serveIndexWithConfig(w http.ResponseWriter, r *http.Request, f
 func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
+			if err == http.ErrAbortHandler {
+				panic(err) // Handlers cutting their response short, see apiStreamTable
+			}
 			var m runtime.MemStats
 			runtime.ReadMemStats(&m)
 			// Report memory usage. OOM happens if we hold references (retention) or exceed system limits,
@@ -149,6 +161,10 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*") // For development
 
+	if strings.HasPrefix(r.URL.Path, "/sqliter/fs/search") {
+		s.apiFindDatabases(w, r)
+		return
+	}
 	if strings.HasPrefix(r.URL.Path, "/sqliter/fs") {
 		s.apiListFiles(w, r)
 		return
@@ -161,6 +177,78 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 		s.apiQueryTable(w, r)
 		return
 	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/stream") {
+		s.apiStreamTable(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/blob") {
+		s.apiBlob(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/fts") {
+		s.apiFTSIndex(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/values") {
+		s.apiDistinctValues(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/profile") {
+		s.apiProfileTable(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/pivot") {
+		s.apiPivot(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/timeseries") {
+		s.apiTimeSeries(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/related") {
+		s.apiRelated(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/erd") {
+		s.apiSchemaGraph(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/diff/data") {
+		s.apiDiffData(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/diff/schema") {
+		s.apiDiffSchema(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/sql") {
+		s.apiExecuteSQL(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/catalog") {
+		s.apiCatalog(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/history") {
+		s.apiHistory(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/explain") {
+		s.apiExplain(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/advisor") {
+		s.apiAdvisor(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/cache") {
+		s.apiCache(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/sqliter/search") {
+		s.apiSearchDatabase(w, r)
+		return
+	}
 	if strings.HasPrefix(r.URL.Path, "/sqliter/logs") {
 		s.handleClientLogs(w, r)
 		return
@@ -206,12 +294,36 @@ func (s *Server) apiListFiles(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(files)
 }
 
+func (s *Server) apiFindDatabases(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	opts := SearchOptions{
+		Query: qs.Get("q"),
+		Dir:   qs.Get("dir"),
+	}
+	opts.MaxDepth, _ = strconv.Atoi(qs.Get("depth"))
+	opts.Limit, _ = strconv.Atoi(qs.Get("limit"))
+	if ignore := qs.Get("ignore"); ignore != "" {
+		opts.Ignore = strings.Split(ignore, ",")
+	}
+
+	matches, err := s.engine.FindDatabases(r.Context(), opts)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(matches)
+}
+
 func (s *Server) apiListTables(w http.ResponseWriter, r *http.Request) {
 	dbName := r.URL.Query().Get("db")
 	if dbName == "" {
 		http.Error(w, `{"error": "db parameter required"}`, http.StatusBadRequest)
 		return
 	}
+	if s.notModified(w, r, dbName) {
+		return
+	}
 
 	tables, err := s.engine.ListTables(r.Context(), dbName)
 	if err != nil {
@@ -230,7 +342,9 @@ func (s *Server) apiListTables(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *Server) apiQueryTable(w http.ResponseWriter, r *http.Request) {
+// parseQueryOptions reads the QueryOptions of the rows API from the request
+// parameters. Pages are capped at maxRows rows when it is positive.
+func parseQueryOptions(r *http.Request, maxRows int) (QueryOptions, error) {
 	// Expecting 'path' parameter which is a Banquet URL, OR separate db/table/params
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -240,8 +354,7 @@ func (s *Server) apiQueryTable(w http.ResponseWriter, r *http.Request) {
 		if db != "" && table != "" {
 			path = "/" + db + "/" + table
 		} else {
-			http.Error(w, `{"error": "path or db+table parameters required"}`, http.StatusBadRequest)
-			return
+			return QueryOptions{}, fmt.Errorf("path or db+table parameters required")
 		}
 	}
 
@@ -260,6 +373,15 @@ func (s *Server) apiQueryTable(w http.ResponseWriter, r *http.Request) {
 	if strings.ToLower(qs.Get("skipTotalCount")) == "true" {
 		opts.SkipTotalCount = true
 	}
+	if strings.ToLower(qs.Get("typed")) == "true" {
+		opts.TypedValues = true
+	}
+	switch strategy := qs.Get("countStrategy"); strategy {
+	case "", CountExact, CountCached, CountEstimated:
+		opts.CountStrategy = strategy
+	default:
+		return QueryOptions{}, fmt.Errorf("invalid countStrategy %q", strategy)
+	}
 
 	// Override limit/offset if provided by AgGrid params
 	if start != "" && end != "" {
@@ -271,15 +393,15 @@ func (s *Server) apiQueryTable(w http.ResponseWriter, r *http.Request) {
 		if limit == 0 {
 			opts.ForceZeroLimit = true
 		}
-	} else {
-		// Default to MaxRowsBuffer (200) buffer limit if no range specified
-		opts.Limit = MaxRowsBuffer
+	} else if maxRows > 0 {
+		// Default to the buffer limit if no range specified
+		opts.Limit = maxRows
 		opts.Offset = 0
 	}
 
 	// Enforce hard limit on buffer size to prevent OOM
-	if opts.Limit > MaxRowsBuffer {
-		opts.Limit = MaxRowsBuffer
+	if maxRows > 0 && opts.Limit > maxRows {
+		opts.Limit = maxRows
 	}
 
 	if sortCol != "" {
@@ -289,27 +411,740 @@ func (s *Server) apiQueryTable(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	opts.QuickFilter = qs.Get("quickFilter")
+
+	// Cross-database queries: attach=alias=path (repeatable)
+	attach, err := parseAttach(qs["attach"])
+	if err != nil {
+		return QueryOptions{}, err
+	}
+	opts.Attach = attach
+
+	// Aggregation mode: groupBy=a,b&agg=sum(amount),count(*)&groupKeys=["x"]&drilldown=true
+	if groupBy := qs.Get("groupBy"); groupBy != "" {
+		opts.GroupBy = strings.Split(groupBy, ",")
+		if aggs := qs.Get("agg"); aggs != "" {
+			for _, a := range strings.Split(aggs, ",") {
+				agg, err := ParseAggregate(a)
+				if err != nil {
+					return QueryOptions{}, err
+				}
+				opts.Aggregates = append(opts.Aggregates, agg)
+			}
+		}
+		if keys := qs.Get("groupKeys"); keys != "" {
+			if err := json.Unmarshal([]byte(keys), &opts.GroupKeys); err != nil {
+				return QueryOptions{}, fmt.Errorf("invalid groupKeys: %w", err)
+			}
+		}
+		opts.Drilldown = strings.ToLower(qs.Get("drilldown")) == "true"
+	}
+
 	// AgGrid Filter Model
 	filterModel := r.URL.Query().Get("filterModel")
 	if filterModel != "" {
 		filterWhere, err := BuildWhereClause(filterModel)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "Error parsing filter: %v"}`, err), http.StatusBadRequest)
-			return
+			return QueryOptions{}, fmt.Errorf("error parsing filter: %w", err)
 		}
 		opts.FilterWhere = filterWhere
 	}
 
+	return opts, nil
+}
+
+func (s *Server) apiQueryTable(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseQueryOptions(r, MaxRowsBuffer)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := negotiateFormat(r)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format == FormatArrow {
+		opts.TypedValues = false // Arrow columns carry their types, and binary BLOBs, already
+	}
+	if db := queryDatabase(opts); db != "" && s.notModified(w, r, db) {
+		return
+	}
+
+	if format == FormatJSON {
+		// JSON is encoded while the rows are read; errors can be reported
+		// as long as nothing was sent
+		w.Header().Set("Content-Type", formatContentTypes[format])
+		ew := &etagWriter{w: w}
+		if err := s.engine.QueryJSON(r.Context(), opts, ew); err != nil {
+			if ew.streaming {
+				s.logError("Failed to stream rows: %v", err)
+				return
+			}
+			s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ew.finish(); err != nil {
+			s.logError("Failed to write rows: %v", err)
+		}
+		return
+	}
+
 	result, err := s.engine.Query(r.Context(), opts)
 	if err != nil {
 		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	if err := writeResult(w, format, result); err != nil {
+		s.logError("Failed to encode rows: %v", err)
+	}
+}
+
+// apiStreamTable streams the rows of /sqliter/rows in chunks, flushed as they
+// are read: JSON lines, MessagePack values or Arrow record batches.
+func (s *Server) apiStreamTable(w http.ResponseWriter, r *http.Request) {
+	// Streams hold one chunk at a time, so they are not capped at MaxRowsBuffer
+	opts, err := parseQueryOptions(r, 0)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := negotiateFormat(r)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format == FormatArrow {
+		opts.TypedValues = false // Arrow columns carry their types, and binary BLOBs, already
+	}
+	contentType := formatContentTypes[format]
+	if format == FormatJSON {
+		contentType = "application/x-ndjson"
+	}
+
+	rc := http.NewResponseController(w)
+	enc := newChunkEncoder(w, format)
+	started := false
+	var encodeErr error
+	err = s.engine.QueryStream(r.Context(), opts, func(chunk QueryResultChunk) error {
+		if !started {
+			w.Header().Set("Content-Type", contentType)
+			started = true
+		}
+		if encodeErr = enc.WriteChunk(chunk); encodeErr != nil {
+			return encodeErr
+		}
+		rc.Flush()
+		return nil
+	})
+	if encodeErr != nil {
+		// Ending the stream normally would pass what was sent for the whole result
+		s.logError("Failed to encode stream: %v", encodeErr)
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil && !started {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// JSON and MessagePack end with an error chunk; Arrow streams cannot carry
+		// one, so they are cut short instead
+		s.logError("Stream failed: %v", err)
+		if format == FormatArrow {
+			panic(http.ErrAbortHandler)
+		}
+		enc.WriteChunk(QueryResultChunk{Error: err.Error()})
+	}
+	if err := enc.Close(); err != nil {
+		s.logError("Failed to encode stream: %v", err)
+	}
+}
+
+// apiExplain returns the plans of the queries /sqliter/rows would run for the same parameters.
+func (s *Server) apiExplain(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseQueryOptions(r, MaxRowsBuffer)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.engine.Explain(r.Context(), opts)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// apiAdvisor lists index suggestions for a database (GET ?db=) or creates one
+// (POST {"db", "table", "columns"}) when the server is writable.
+func (s *Server) apiAdvisor(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		db := r.URL.Query().Get("db")
+		if db == "" {
+			http.Error(w, `{"error": "db parameter required"}`, http.StatusBadRequest)
+			return
+		}
+		suggestions, err := s.engine.AdviseIndexes(r.Context(), db)
+		if err != nil {
+			s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(suggestions)
+	case http.MethodPost:
+		var req struct {
+			DB      string   `json:"db"`
+			Table   string   `json:"table"`
+			Columns []string `json:"columns"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.writeJSONError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.DB == "" || req.Table == "" || len(req.Columns) == 0 {
+			http.Error(w, `{"error": "db, table and columns required"}`, http.StatusBadRequest)
+			return
+		}
+		sug, err := s.engine.ApplyIndex(r.Context(), req.DB, req.Table, req.Columns)
+		if errors.Is(err, ErrReadOnly) {
+			s.writeJSONError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(sug)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// apiCache reports the result cache statistics (GET) or empties the caches (DELETE).
+func (s *Server) apiCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		if !s.config.Writable {
+			s.writeJSONError(w, "server is read-only", http.StatusForbidden)
+			return
+		}
+		s.engine.ClearCache()
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(s.engine.CacheStats())
+}
+
+func (s *Server) apiSearchDatabase(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	opts := SearchDatabaseOptions{
+		DB:    qs.Get("db"),
+		Query: qs.Get("q"),
+	}
+	if opts.DB == "" || opts.Query == "" {
+		http.Error(w, `{"error": "db and q parameters required"}`, http.StatusBadRequest)
+		return
+	}
+	if tables := qs.Get("tables"); tables != "" {
+		opts.Tables = strings.Split(tables, ",")
+	}
+	opts.LimitPerTable, _ = strconv.Atoi(qs.Get("limit"))
+	if ms, err := strconv.Atoi(qs.Get("timeout")); err == nil {
+		opts.TableTimeout = time.Duration(ms) * time.Millisecond
+	}
+
+	result, err := s.engine.SearchDatabase(r.Context(), opts)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) apiDistinctValues(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	path := qs.Get("path")
+	column := qs.Get("column")
+	if path == "" || column == "" {
+		http.Error(w, `{"error": "path and column parameters required"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts := ValuesOptions{
+		FilterModelJSON: qs.Get("filterModel"),
+		Search:          qs.Get("search"),
+		SortBy:          qs.Get("sortBy"),
+	}
+	opts.Offset, _ = strconv.Atoi(qs.Get("offset"))
+	opts.Limit, _ = strconv.Atoi(qs.Get("limit"))
+
+	result, err := s.engine.DistinctValues(r.Context(), path, column, opts)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) apiProfileTable(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	path := qs.Get("path")
+	if path == "" {
+		http.Error(w, `{"error": "path parameter required"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts := ProfileOptions{FilterModelJSON: qs.Get("filterModel")}
+	if cols := qs.Get("columns"); cols != "" {
+		opts.Columns = strings.Split(cols, ",")
+	}
+	opts.SampleSize, _ = strconv.Atoi(qs.Get("sample"))
+	opts.TopN, _ = strconv.Atoi(qs.Get("top"))
+	opts.Bins, _ = strconv.Atoi(qs.Get("bins"))
+
+	profile, err := s.engine.ProfileTable(r.Context(), path, opts)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (s *Server) apiPivot(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	opts := PivotOptions{
+		BanquetPath:     qs.Get("path"),
+		FilterModelJSON: qs.Get("filterModel"),
+		PivotColumn:     qs.Get("pivot"),
+		ValueColumn:     qs.Get("value"),
+		Aggregate:       qs.Get("agg"),
+	}
+	if rows := qs.Get("rows"); rows != "" {
+		opts.RowKeys = strings.Split(rows, ",")
+	}
+	if opts.BanquetPath == "" || len(opts.RowKeys) == 0 || opts.PivotColumn == "" {
+		http.Error(w, `{"error": "path, rows and pivot parameters required"}`, http.StatusBadRequest)
+		return
+	}
+	opts.MaxPivotValues, _ = strconv.Atoi(qs.Get("max"))
+
+	result, err := s.engine.Pivot(r.Context(), opts)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	json.NewEncoder(w).Encode(result)
 }
 
+func (s *Server) apiTimeSeries(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	opts := TimeSeriesOptions{
+		BanquetPath:     qs.Get("path"),
+		FilterModelJSON: qs.Get("filterModel"),
+		TimeColumn:      qs.Get("column"),
+		Bucket:          qs.Get("bucket"),
+		Aggregate:       qs.Get("agg"),
+		ValueColumn:     qs.Get("value"),
+	}
+	if opts.BanquetPath == "" || opts.TimeColumn == "" {
+		http.Error(w, `{"error": "path and column parameters required"}`, http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.engine.TimeSeries(r.Context(), opts)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) apiRelated(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	path := qs.Get("path")
+	rowid, err := strconv.ParseInt(qs.Get("rowid"), 10, 64)
+	if path == "" || err != nil {
+		http.Error(w, `{"error": "path and numeric rowid parameters required"}`, http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.engine.Related(r.Context(), path, rowid)
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// apiBlob serves one cell, typically a BLOB, as a file: its Content-Type is
+// sniffed from its bytes, and Range and conditional requests are supported.
+func (s *Server) apiBlob(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	path := qs.Get("path")
+	column := qs.Get("column")
+	rowid, err := strconv.ParseInt(qs.Get("rowid"), 10, 64)
+	if path == "" || column == "" || err != nil {
+		http.Error(w, `{"error": "path, column and numeric rowid parameters required"}`, http.StatusBadRequest)
+		return
+	}
+
+	cell, err := s.engine.OpenBlob(r.Context(), path, column, rowid)
+	if errors.Is(err, ErrCellNotFound) {
+		s.writeJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Del("Content-Type") // Sniffed by ServeContent
+	w.Header().Set("ETag", cellETag(path, column, rowid, cell.Version))
+	w.Header().Set("Cache-Control", apiCacheControl)
+	// Cells are untrusted content: never let a sniffed HTML or SVG cell run scripts
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	http.ServeContent(w, r, "", time.Time{}, io.NewSectionReader(cell, 0, cell.Size))
+}
+
+// apiSchemaGraph returns the tables and foreign keys of a database (?db=) as
+// JSON, Graphviz DOT or Mermaid (?format=), with inferred edges when ?infer=true.
+func (s *Server) apiSchemaGraph(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	dbName := qs.Get("db")
+	if dbName == "" {
+		http.Error(w, `{"error": "db parameter required"}`, http.StatusBadRequest)
+		return
+	}
+
+	graph, err := s.engine.SchemaGraph(r.Context(), dbName, SchemaGraphOptions{InferEdges: qs.Get("infer") == "true"})
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch qs.Get("format") {
+	case "", "json":
+		json.NewEncoder(w).Encode(graph)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		w.Write([]byte(graph.DOT()))
+	case "mermaid":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(graph.Mermaid()))
+	default:
+		s.writeJSONError(w, "format must be json, dot or mermaid", http.StatusBadRequest)
+	}
+}
+
+func (s *Server) apiDiffSchema(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	dbA, dbB := qs.Get("a"), qs.Get("b")
+	if dbA == "" || dbB == "" {
+		http.Error(w, `{"error": "a and b parameters required"}`, http.StatusBadRequest)
+		return
+	}
+
+	diff, err := s.engine.DiffSchema(r.Context(), dbA, dbB, DiffSchemaOptions{Migration: qs.Get("migration") == "true"})
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(diff)
+}
+
+func (s *Server) apiDiffData(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	opts := DataDiffOptions{A: qs.Get("a"), B: qs.Get("b")}
+	if opts.A == "" || opts.B == "" {
+		http.Error(w, `{"error": "a and b parameters required"}`, http.StatusBadRequest)
+		return
+	}
+	if key := qs.Get("key"); key != "" {
+		opts.Key = strings.Split(key, ",")
+	}
+	format := qs.Get("format")
+	switch format {
+	case "":
+		format = "jsonl"
+		fallthrough
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	default:
+		s.writeJSONError(w, "format must be jsonl or csv", http.StatusBadRequest)
+		return
+	}
+
+	// Errors before the first row still get a JSON error; later ones can only be logged
+	cw := &countingWriter{w: w, flush: http.NewResponseController(w).Flush, lastFlush: time.Now()}
+	if _, err := s.engine.ExportDataDiff(r.Context(), opts, format, cw); err != nil {
+		if cw.n == 0 {
+			s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.logError("Data diff failed: %v", err)
+	}
+}
+
+// streamFlushInterval is how often streamed responses are flushed to the client.
+const streamFlushInterval = 200 * time.Millisecond
+
+// countingWriter counts the bytes written through it. With a flush function, it
+// also flushes them every streamFlushInterval so streamed rows arrive in chunks.
+type countingWriter struct {
+	w         io.Writer
+	n         int64
+	flush     func() error
+	lastFlush time.Time
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err == nil && c.flush != nil && time.Since(c.lastFlush) >= streamFlushInterval {
+		err = c.flush()
+		c.lastFlush = time.Now()
+	}
+	return n, err
+}
+
+// parseAttach parses attach parameters of the form alias=path.
+func parseAttach(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	attach := make(map[string]string, len(values))
+	for _, v := range values {
+		alias, path, ok := strings.Cut(v, "=")
+		if !ok || alias == "" || path == "" {
+			return nil, fmt.Errorf("invalid attach %q, expected alias=path", v)
+		}
+		attach[alias] = path
+	}
+	return attach, nil
+}
+
+func (s *Server) apiExecuteSQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeJSONError(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	var opts SQLOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		s.writeJSONError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if opts.DB == "" || opts.SQL == "" {
+		http.Error(w, `{"error": "db and sql required"}`, http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.engine.ExecuteSQL(r.Context(), opts)
+	if errors.Is(err, ErrRejected) {
+		s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// apiCatalog serves CRUD for saved queries: GET lists (?q=search) or fetches one
+// (?id= or ?name=), POST creates, PUT ?id= updates and DELETE ?id= removes.
+func (s *Server) apiCatalog(w http.ResponseWriter, r *http.Request) {
+	catalog, err := s.engine.Catalog()
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	qs := r.URL.Query()
+	id, _ := strconv.ParseInt(qs.Get("id"), 10, 64)
+
+	// Listing and clearing include the queries that just ran
+	if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+		s.engine.FlushHistory()
+	}
+
+	var result interface{}
+	switch r.Method {
+	case http.MethodGet:
+		if id != 0 || qs.Get("name") != "" {
+			result, err = catalog.Get(r.Context(), id, qs.Get("name"))
+		} else {
+			result, err = catalog.List(r.Context(), qs.Get("q"))
+		}
+	case http.MethodPost, http.MethodPut:
+		var q SavedQuery
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			s.writeJSONError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		q.ID = id
+		if r.Method == http.MethodPut && id == 0 {
+			s.writeJSONError(w, "id parameter required", http.StatusBadRequest)
+			return
+		}
+		result, err = catalog.Save(r.Context(), q)
+	case http.MethodDelete:
+		err = catalog.Delete(r.Context(), id)
+		result = map[string]bool{"deleted": err == nil}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if errors.Is(err, ErrSavedQueryNotFound) {
+		s.writeJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// apiHistory serves the query history: GET lists (?q=, db, pinned, minMs, sort,
+// limit, offset) or fetches ?id=, POST ?id=&action=pin|unpin|replay, and
+// DELETE clears all unpinned entries.
+func (s *Server) apiHistory(w http.ResponseWriter, r *http.Request) {
+	catalog, err := s.engine.Catalog()
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	qs := r.URL.Query()
+	id, _ := strconv.ParseInt(qs.Get("id"), 10, 64)
+
+	// Listing and clearing include the queries that just ran
+	if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+		s.engine.FlushHistory()
+	}
+
+	var result interface{}
+	switch r.Method {
+	case http.MethodGet:
+		if id != 0 {
+			result, err = catalog.GetHistory(r.Context(), id)
+			break
+		}
+		f := HistoryFilter{
+			Search:     qs.Get("q"),
+			DB:         qs.Get("db"),
+			PinnedOnly: qs.Get("pinned") == "true",
+			SortBy:     qs.Get("sort"),
+		}
+		f.MinDurationMs, _ = strconv.ParseFloat(qs.Get("minMs"), 64)
+		f.Limit, _ = strconv.Atoi(qs.Get("limit"))
+		f.Offset, _ = strconv.Atoi(qs.Get("offset"))
+		result, err = catalog.ListHistory(r.Context(), f)
+	case http.MethodPost:
+		switch qs.Get("action") {
+		case "pin", "unpin":
+			err = catalog.PinHistory(r.Context(), id, qs.Get("action") == "pin")
+			if err == nil {
+				result, err = catalog.GetHistory(r.Context(), id)
+			}
+		case "replay":
+			result, err = s.engine.ReplayHistory(r.Context(), id)
+		default:
+			s.writeJSONError(w, "action must be pin, unpin or replay", http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		err = catalog.ClearHistory(r.Context())
+		result = map[string]bool{"cleared": err == nil}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if errors.Is(err, ErrHistoryNotFound) {
+		s.writeJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// apiFTSIndex reports (GET), creates or rebuilds (POST) and drops (DELETE) the FTS5 index of a table.
+func (s *Server) apiFTSIndex(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DB      string   `json:"db"`
+		Table   string   `json:"table"`
+		Columns []string `json:"columns"`
+		Action  string   `json:"action"` // "create" (default) or "rebuild"
+	}
+	req.DB = r.URL.Query().Get("db")
+	req.Table = r.URL.Query().Get("table")
+
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.writeJSONError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.DB == "" || req.Table == "" {
+		http.Error(w, `{"error": "db and table parameters required"}`, http.StatusBadRequest)
+		return
+	}
+	var (
+		idx *FTSIndex
+		err error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		idx, err = s.engine.GetFTSIndex(r.Context(), req.DB, req.Table)
+	case http.MethodPost:
+		if req.Action == "rebuild" {
+			if err = s.engine.RebuildFTSIndex(r.Context(), req.DB, req.Table); err == nil {
+				idx, err = s.engine.GetFTSIndex(r.Context(), req.DB, req.Table)
+			}
+		} else {
+			idx, err = s.engine.CreateFTSIndex(r.Context(), req.DB, req.Table, req.Columns)
+		}
+	case http.MethodDelete:
+		err = s.engine.DropFTSIndex(r.Context(), req.DB, req.Table)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if errors.Is(err, ErrReadOnly) {
+		s.writeJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		s.writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"index": idx})
+}
+
 func (s *Server) writeJSONError(w http.ResponseWriter, msg string, code int) {
+	// Errors must not be revalidated as if they were the cached response
+	w.Header().Del("ETag")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(map[string]string{"error": msg})
@@ -0,0 +1,205 @@
+package sqliter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// MaxTimeSeriesBuckets bounds the gap-filled series so a wide date range with a
+// small bucket size cannot produce an unbounded response.
+const MaxTimeSeriesBuckets = 10000
+
+// TimeSeriesOptions describes a bucketed aggregate over a date/time column.
+type TimeSeriesOptions struct {
+	BanquetPath     string
+	FilterWhere     string // SQL fragment
+	FilterModelJSON string // AgGrid Filter Model JSON
+	TimeColumn      string // ISO-8601 text, unix seconds or milliseconds, or julian days
+	Bucket          string // minute, hour, day (default), week, month, quarter or year
+	Aggregate       string // count (default), sum, avg, min or max
+	ValueColumn     string // Column aggregated per bucket; not needed for count
+}
+
+// TimeBucket is one point of a time series. Start is formatted like the bucket:
+// "2006-01-02T15:04:00" for minutes and hours, "2006-01-02" otherwise.
+type TimeBucket struct {
+	Start string      `json:"start"`
+	Count int64       `json:"count"`
+	Value interface{} `json:"value"`
+}
+
+// TimeSeriesResult is a gap-filled, ordered time series.
+type TimeSeriesResult struct {
+	Column    string       `json:"column"`
+	Bucket    string       `json:"bucket"`
+	Aggregate string       `json:"aggregate"`
+	Buckets   []TimeBucket `json:"buckets"`
+	Skipped   int64        `json:"skipped"` // Rows whose time was NULL or unparseable
+	SQL       string       `json:"sql"`
+}
+
+type bucketSpec struct {
+	label  string // SQL expression over {ts} (unix seconds) producing the bucket start
+	layout string // Go layout of the label
+	next   func(time.Time) time.Time
+}
+
+var bucketSpecs = map[string]bucketSpec{
+	"minute": {"strftime('%Y-%m-%dT%H:%M:00', {ts}, 'unixepoch')", "2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	"hour":   {"strftime('%Y-%m-%dT%H:00:00', {ts}, 'unixepoch')", "2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Hour) }},
+	"day":    {"date({ts}, 'unixepoch')", "2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	// Weeks start on Monday: step back six days, then forward to the next Monday
+	"week":    {"date({ts}, 'unixepoch', '-6 days', 'weekday 1')", "2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }},
+	"month":   {"strftime('%Y-%m-01', {ts}, 'unixepoch')", "2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	"quarter": {"printf('%s-%02d-01', strftime('%Y', {ts}, 'unixepoch'), ((CAST(strftime('%m', {ts}, 'unixepoch') AS INTEGER) - 1) / 3) * 3 + 1)", "2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 3, 0) }},
+	"year":    {"strftime('%Y-01-01', {ts}, 'unixepoch')", "2006-01-02", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+}
+
+// unixSecondsExpr converts a column holding ISO-8601 text, unix seconds,
+// unix milliseconds or julian day numbers into unix seconds. Numbers are told
+// apart by magnitude: >= 1e11 are milliseconds, 1e6..5e6 are julian days
+// (years -1975..8977), anything else is seconds. Numeric text is treated as a number.
+func unixSecondsExpr(col string) string {
+	num := func(x string) string {
+		return fmt.Sprintf("CASE WHEN abs(%[1]s) >= 1e11 THEN %[1]s / 1000.0 WHEN %[1]s BETWEEN 1e6 AND 5e6 THEN (%[1]s - 2440587.5) * 86400 ELSE %[1]s END", x)
+	}
+	return fmt.Sprintf(`CASE
+		WHEN typeof(%[1]s) IN ('integer', 'real') THEN %[2]s
+		WHEN typeof(%[1]s) = 'text' AND trim(%[1]s) != '' AND trim(%[1]s) NOT GLOB '*[^0-9.]*' THEN %[3]s
+		WHEN typeof(%[1]s) = 'text' THEN CAST(strftime('%%s', %[1]s) AS INTEGER)
+	END`, col, num(col), num("CAST(trim("+col+") AS REAL)"))
+}
+
+// TimeSeries buckets the rows selected by a Banquet path by a date/time column and
+// aggregates each bucket. Empty buckets between the first and last are filled in
+// with a zero count (and zero for count/sum, null for other aggregates).
+func (e *Engine) TimeSeries(ctx context.Context, opts TimeSeriesOptions) (*TimeSeriesResult, error) {
+	if opts.TimeColumn == "" {
+		return nil, fmt.Errorf("time column required")
+	}
+	if opts.Bucket == "" {
+		opts.Bucket = "day"
+	}
+	spec, ok := bucketSpecs[opts.Bucket]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bucket: %s", opts.Bucket)
+	}
+	agg := Aggregate{Func: strings.ToLower(opts.Aggregate), Column: opts.ValueColumn}
+	if agg.Func == "" {
+		agg.Func = "count"
+	}
+	if agg.Func == "group_concat" {
+		return nil, fmt.Errorf("unsupported aggregate function: %s", agg.Func)
+	}
+	if err := agg.validate(); err != nil {
+		return nil, err
+	}
+
+	pq, err := e.prepareQuery(ctx, QueryOptions{
+		BanquetPath:     opts.BanquetPath,
+		FilterWhere:     opts.FilterWhere,
+		FilterModelJSON: opts.FilterModelJSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cols, err := tableColumns(ctx, pq.db, pq.bq.Table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	for _, c := range []string{opts.TimeColumn, opts.ValueColumn} {
+		if c != "" && c != "*" && !containsString(cols, c) {
+			return nil, fmt.Errorf("no such column: %s", c)
+		}
+	}
+
+	// The unix seconds column is added to the table's own, so its name must not clash
+	tsName := "__ts"
+	for containsFold(cols, tsName) {
+		tsName += "_"
+	}
+	ts := sqlite.QuoteIdentifier(tsName)
+	inner := fmt.Sprintf("SELECT *, %s AS %s FROM %s", unixSecondsExpr(sqlite.QuoteIdentifier(opts.TimeColumn)), ts, sqlite.QuoteIdentifier(pq.bq.Table))
+	if pq.bq.Where != "" {
+		inner += " WHERE " + pq.bq.Where
+	}
+
+	result := &TimeSeriesResult{
+		Column:    opts.TimeColumn,
+		Bucket:    opts.Bucket,
+		Aggregate: agg.Func,
+		Buckets:   make([]TimeBucket, 0),
+	}
+	result.SQL = fmt.Sprintf("SELECT %s AS bucket, COUNT(*), %s FROM (%s) GROUP BY 1 ORDER BY 1",
+		strings.ReplaceAll(spec.label, "{ts}", ts), agg.expr(), inner)
+
+	rows, err := pq.db.QueryContext(ctx, result.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	var points []TimeBucket
+	for rows.Next() {
+		var label *string
+		var b TimeBucket
+		if err := rows.Scan(&label, &b.Count, &b.Value); err != nil {
+			continue
+		}
+		if label == nil {
+			result.Skipped += b.Count
+			continue
+		}
+		b.Start = *label
+		b.Value = normalizeValue(b.Value)
+		points = append(points, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	result.Buckets, err = fillBuckets(points, spec, agg.Func)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fillBuckets inserts empty buckets between the ordered points.
+func fillBuckets(points []TimeBucket, spec bucketSpec, fn string) ([]TimeBucket, error) {
+	filled := make([]TimeBucket, 0, len(points))
+	if len(points) == 0 {
+		return filled, nil
+	}
+
+	var empty interface{}
+	if fn == "count" || fn == "sum" {
+		empty = int64(0)
+	}
+
+	cur, err := time.Parse(spec.layout, points[0].Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bucket %q: %w", points[0].Start, err)
+	}
+	for _, p := range points {
+		t, err := time.Parse(spec.layout, p.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %w", p.Start, err)
+		}
+		for cur.Before(t) {
+			filled = append(filled, TimeBucket{Start: cur.Format(spec.layout), Value: empty})
+			if len(filled) > MaxTimeSeriesBuckets {
+				return nil, fmt.Errorf("time series exceeds %d buckets, use a larger bucket size", MaxTimeSeriesBuckets)
+			}
+			cur = spec.next(cur)
+		}
+		filled = append(filled, p)
+		cur = spec.next(t)
+	}
+	return filled, nil
+}
@@ -0,0 +1,118 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestTimeSeries(t *testing.T) {
+	tmpDir := t.TempDir()
+	// The same instants in every supported encoding:
+	// 2024-01-01 (1704067200), 2024-01-03 (1704240000), 2024-01-03 12:00
+	createTestDB(t, filepath.Join(tmpDir, "events.db"), `
+		CREATE TABLE events (ts, amount INTEGER);
+		INSERT INTO events VALUES
+			('2024-01-01T08:30:00Z', 1),
+			(1704067200, 2),
+			(1704240000000, 3),
+			(2460313.0, 4),
+			('1704283200', 5),
+			('not a date', 6),
+			(NULL, 7);
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	res, err := engine.TimeSeries(ctx, TimeSeriesOptions{
+		BanquetPath: "/events.db/events",
+		TimeColumn:  "ts",
+		Bucket:      "day",
+		Aggregate:   "sum",
+		ValueColumn: "amount",
+	})
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+	if res.Skipped != 2 {
+		t.Errorf("Expected 2 skipped rows, got %d", res.Skipped)
+	}
+	expected := []struct {
+		start string
+		count int64
+		value interface{}
+	}{
+		{"2024-01-01", 2, int64(3)},
+		{"2024-01-02", 0, int64(0)},
+		{"2024-01-03", 3, int64(12)},
+	}
+	if len(res.Buckets) != len(expected) {
+		t.Fatalf("Expected %d buckets, got %+v", len(expected), res.Buckets)
+	}
+	for i, want := range expected {
+		got := res.Buckets[i]
+		if got.Start != want.start || got.Count != want.count || got.Value != want.value {
+			t.Errorf("Bucket %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	t.Run("Week buckets start on Monday", func(t *testing.T) {
+		res, err := engine.TimeSeries(ctx, TimeSeriesOptions{BanquetPath: "/events.db/events", TimeColumn: "ts", Bucket: "week"})
+		if err != nil {
+			t.Fatalf("TimeSeries failed: %v", err)
+		}
+		if len(res.Buckets) != 1 || res.Buckets[0].Start != "2024-01-01" || res.Buckets[0].Count != 5 {
+			t.Errorf("Expected a single week starting Monday 2024-01-01, got %+v", res.Buckets)
+		}
+	})
+
+	t.Run("Invalid bucket", func(t *testing.T) {
+		if _, err := engine.TimeSeries(ctx, TimeSeriesOptions{BanquetPath: "/events.db/events", TimeColumn: "ts", Bucket: "fortnight"}); err == nil {
+			t.Errorf("Expected error for unsupported bucket")
+		}
+	})
+
+	t.Run("Columns named like the bucket", func(t *testing.T) {
+		createTestDB(t, filepath.Join(tmpDir, "events.db"), `
+			CREATE TABLE clash (__ts, bucket INTEGER);
+			INSERT INTO clash VALUES ('2024-01-01', 1), ('2024-01-02', 2), ('2024-01-02', 3);
+		`)
+		res, err := engine.TimeSeries(ctx, TimeSeriesOptions{
+			BanquetPath: "/events.db/clash",
+			TimeColumn:  "__ts",
+			Bucket:      "day",
+			Aggregate:   "sum",
+			ValueColumn: "bucket",
+		})
+		if err != nil {
+			t.Fatalf("TimeSeries failed: %v", err)
+		}
+		if len(res.Buckets) != 2 || res.Buckets[1].Start != "2024-01-02" || res.Buckets[1].Value != int64(5) {
+			t.Errorf("Expected two daily buckets summing to 1 and 5, got %+v", res.Buckets)
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/timeseries?path=/events.db/events&column=ts&bucket=hour", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body TimeSeriesResult
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		// 2024-01-01T00:00 through 2024-01-03T12:00 inclusive
+		if len(body.Buckets) != 61 || body.Buckets[0].Start != "2024-01-01T00:00:00" {
+			t.Errorf("Expected 61 hourly buckets from midnight, got %d starting %+v", len(body.Buckets), body.Buckets[0])
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package sqliter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darianmavgo/banquet"
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// SQLite storage classes, as reported by typeof().
+const (
+	StorageNull    = "null"
+	StorageInteger = "integer"
+	StorageReal    = "real"
+	StorageText    = "text"
+	StorageBlob    = "blob"
+)
+
+// maxSafeInteger is the largest integer a JSON number holds exactly in
+// JavaScript (Number.MAX_SAFE_INTEGER).
+const maxSafeInteger = 1<<53 - 1
+
+// sqliteTimeFormat renders times as SQLite's date functions do.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999"
+
+// TypedValue is a cell together with its storage class, returned instead of
+// bare values with QueryOptions.TypedValues so clients can tell NULL from
+// empty values and round-trip them exactly:
+//
+//   - integers beyond ±(2^53-1) are strings, as JSON numbers would lose precision
+//   - reals that JSON cannot represent are "Infinity" or "-Infinity"
+//   - BLOBs are base64, with their length and sniffed MIME type
+//
+// Columns declared DATE, DATETIME or TIMESTAMP are read as stored (see
+// rawTimeQuery). Where the driver still parses their text, as in aggregates,
+// times are rendered in SQLite's own format.
+type TypedValue struct {
+	Type   string      `json:"type"`
+	Value  interface{} `json:"value,omitempty"`  // Absent for NULL
+	Length *int        `json:"length,omitempty"` // BLOB size in bytes
+	MIME   string      `json:"mime,omitempty"`   // Detected content type of a BLOB
+	URL    string      `json:"url,omitempty"`    // Address of an image BLOB at /sqliter/blob
+}
+
+// typedValue wraps a scanned value in its envelope.
+func typedValue(v interface{}) TypedValue {
+	switch v := v.(type) {
+	case nil:
+		return TypedValue{Type: StorageNull}
+	case int64:
+		if v > maxSafeInteger || v < -maxSafeInteger {
+			return TypedValue{Type: StorageInteger, Value: strconv.FormatInt(v, 10)}
+		}
+		return TypedValue{Type: StorageInteger, Value: v}
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return TypedValue{Type: StorageReal, Value: formatNonFinite(v)}
+		}
+		return TypedValue{Type: StorageReal, Value: v}
+	case string:
+		return TypedValue{Type: StorageText, Value: v}
+	case []byte:
+		n := len(v)
+		return TypedValue{Type: StorageBlob, Value: base64.StdEncoding.EncodeToString(v), Length: &n, MIME: sniffMIME(v)}
+	case bool:
+		if v {
+			return TypedValue{Type: StorageInteger, Value: int64(1)}
+		}
+		return TypedValue{Type: StorageInteger, Value: int64(0)}
+	case time.Time:
+		return TypedValue{Type: StorageText, Value: formatSQLiteTime(v)}
+	}
+	return TypedValue{Type: StorageText, Value: fmt.Sprint(v)}
+}
+
+func formatNonFinite(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return "NaN"
+}
+
+func formatSQLiteTime(t time.Time) string {
+	if _, offset := t.Zone(); offset != 0 {
+		return t.Format(sqliteTimeFormat + "-07:00")
+	}
+	return t.Format(sqliteTimeFormat)
+}
+
+// sniffMIME detects a BLOB's content type from its leading bytes.
+func sniffMIME(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return http.DetectContentType(b)
+}
+
+// typedRow wraps the values of a row in envelopes, adding the /sqliter/blob
+// URLs of its image cells.
+func typedRow(values []interface{}, blobURL func(int) string) []interface{} {
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		tv := typedValue(v)
+		tv.URL = imageURL(tv.MIME, blobURL, i)
+		row[i] = tv
+	}
+	return row
+}
+
+// imageURL returns the URL of cell col if its MIME type is an image.
+func imageURL(mime string, blobURL func(int) string, col int) string {
+	if blobURL == nil || !strings.HasPrefix(mime, "image/") {
+		return ""
+	}
+	return blobURL(col)
+}
+
+// appendTypedJSONRow appends a row of TypedValue envelopes as JSON, encoded
+// straight from the scanned values. blobURL addresses the image cells of the
+// row by column index.
+func appendTypedJSONRow(b []byte, row []interface{}, blobURL func(int) string) []byte {
+	b = append(b, '[')
+	for i, v := range row {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendTypedJSON(b, v, blobURL, i)
+	}
+	return append(b, ']')
+}
+
+// appendTypedJSON appends the JSON encoding of typedValue(v) for cell col,
+// with its URL if it is an image. BLOBs are encoded in place rather than
+// through an intermediate base64 string.
+func appendTypedJSON(b []byte, v interface{}, blobURL func(int) string, col int) []byte {
+	blob, ok := v.([]byte)
+	if !ok {
+		tv := typedValue(v)
+		b = append(b, `{"type":`...)
+		b = appendJSONString(b, tv.Type)
+		if tv.Value != nil {
+			b = append(b, `,"value":`...)
+			b = appendJSONValue(b, tv.Value)
+		}
+		return append(b, '}')
+	}
+	b = append(b, `{"type":"blob","value":"`...)
+	b = base64.StdEncoding.AppendEncode(b, blob)
+	b = append(b, `","length":`...)
+	b = strconv.AppendInt(b, int64(len(blob)), 10)
+	if mime := sniffMIME(blob); mime != "" {
+		b = append(b, `,"mime":`...)
+		b = appendJSONString(b, mime)
+		if url := imageURL(mime, blobURL, col); url != "" {
+			b = append(b, `,"url":`...)
+			b = appendJSONString(b, url)
+		}
+	}
+	return append(b, '}')
+}
+
+// rawTimeQuery rewrites the select list of a composed page query so that
+// columns declared DATE, DATETIME or TIMESTAMP are selected as +"column". The
+// driver parses the text of such columns into times, which cannot be rendered
+// back byte for byte; SQLite's unary plus returns the stored value unchanged but
+// has no declared type. It also returns the declared types of the rewritten
+// columns, which the result still reports.
+func rawTimeQuery(ctx context.Context, db *sql.DB, bq *banquet.Banquet, query string) (string, map[string]string) {
+	rows, err := db.QueryContext(ctx, "SELECT name, upper(type) FROM pragma_table_info(?)", bq.Table)
+	if err != nil {
+		return query, nil
+	}
+	var cols []string
+	decls := map[string]string{}
+	for rows.Next() {
+		var name, decl string
+		if err := rows.Scan(&name, &decl); err != nil {
+			rows.Close()
+			return query, nil
+		}
+		cols = append(cols, name)
+		decls[name] = decl
+	}
+	rows.Close()
+
+	selected := bq.Select
+	if len(selected) == 0 || selected[0] == "*" {
+		selected = cols
+	}
+	table := sqlite.QuoteIdentifier(bq.Table)
+	times := map[string]string{}
+	list := make([]string, len(selected))
+	for i, name := range selected {
+		col := table + "." + sqlite.QuoteIdentifier(name)
+		switch decl := decls[name]; decl {
+		case "DATE", "DATETIME", "TIMESTAMP":
+			times[name] = decl
+			col = "+" + col + " AS " + sqlite.QuoteIdentifier(name)
+		}
+		list[i] = col
+	}
+	// Quoted names cannot contain the FROM clause
+	from := strings.Index(query, " FROM "+table)
+	if len(times) == 0 || from < 0 {
+		return query, nil
+	}
+	return "SELECT " + strings.Join(list, ", ") + query[from:], times
+}
@@ -0,0 +1,146 @@
+package sqliter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestTypedValue(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for _, tc := range []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, `{"type":"null"}`},
+		{int64(42), `{"type":"integer","value":42}`},
+		{int64(1<<53 - 1), `{"type":"integer","value":9007199254740991}`},
+		{int64(1<<53 + 1), `{"type":"integer","value":"9007199254740993"}`},
+		{int64(-1 << 63), `{"type":"integer","value":"-9223372036854775808"}`},
+		{2.5, `{"type":"real","value":2.5}`},
+		{math.Inf(-1), `{"type":"real","value":"-Infinity"}`},
+		{"", `{"type":"text","value":""}`},
+		{"<a>", `{"type":"text","value":"\u003ca\u003e"}`},
+		{[]byte{}, `{"type":"blob","value":"","length":0}`},
+		{png, `{"type":"blob","value":"` + base64.StdEncoding.EncodeToString(png) + `","length":16,"mime":"image/png"}`},
+		{time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), `{"type":"text","value":"2024-05-06 07:08:09"}`},
+	} {
+		enc, err := json.Marshal(typedValue(tc.in))
+		if err != nil {
+			t.Fatalf("json.Marshal: %v", err)
+		}
+		if string(enc) != tc.want {
+			t.Errorf("typedValue(%#v) = %s, want %s", tc.in, enc, tc.want)
+		}
+		if got := appendTypedJSON(nil, tc.in, nil, 0); string(got) != tc.want {
+			t.Errorf("appendTypedJSON(%#v) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "files.db"), `
+		CREATE TABLE files (id INTEGER PRIMARY KEY, name TEXT, size INTEGER, body BLOB);
+		INSERT INTO files (name, size, body) VALUES
+			('logo.png', 9007199254740993, x'89504e470d0a1a0a0000000d49484452'),
+			('', 0, x''),
+			(NULL, NULL, NULL),
+			('notes', 5, 'hello');
+	`)
+	ctx := context.Background()
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	opts := QueryOptions{BanquetPath: "/files.db/files", TypedValues: true}
+	res, err := engine.Query(ctx, opts)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var want bytes.Buffer
+	json.NewEncoder(&want).Encode(res)
+	var got bytes.Buffer
+	if err := engine.QueryJSON(ctx, opts, &got); err != nil {
+		t.Fatalf("QueryJSON failed: %v", err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("QueryJSON output differs from Query:\n got %s\nwant %s", got.String(), want.String())
+	}
+
+	srv := NewServer(&Config{ServeFolder: tmpDir})
+	defer srv.engine.CloseAll()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?path=/files.db/files&typed=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var out struct {
+		Values [][]TypedValue `json:"values"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || len(out.Values) != 4 {
+		t.Fatalf("Unexpected response %s: %v", w.Body.String(), err)
+	}
+	logo, empty, null, notes := out.Values[0], out.Values[1], out.Values[2], out.Values[3]
+	if logo[2].Value != "9007199254740993" || logo[3].MIME != "image/png" || *logo[3].Length != 16 {
+		t.Errorf("Unexpected logo row: %+v", logo)
+	}
+	if empty[1].Type != StorageText || empty[1].Value != "" || empty[3].Type != StorageBlob || *empty[3].Length != 0 {
+		t.Errorf("Expected empty text and blob, got %+v", empty)
+	}
+	if null[1].Type != StorageNull || null[3].Type != StorageNull {
+		t.Errorf("Expected NULLs, got %+v", null)
+	}
+	if notes[3].Type != StorageText || notes[3].Value != "hello" {
+		t.Errorf("Expected text stored in a BLOB column to stay text, got %+v", notes[3])
+	}
+
+	t.Run("Dates are read as stored", func(t *testing.T) {
+		createTestDB(t, filepath.Join(tmpDir, "files.db"), `
+			CREATE TABLE events (id INTEGER PRIMARY KEY, at DATETIME, day DATE);
+			INSERT INTO events (at, day) VALUES
+				('2024-01-01T10:00:00Z', '2024-01-01'),
+				(1704103200, 'someday');
+		`)
+		// Text sorts after integers, so both orders list the text row first
+		for _, opts := range []QueryOptions{
+			{BanquetPath: "/files.db/events", TypedValues: true},
+			{BanquetPath: "/files.db/events/at,id", SortCol: "at", SortDir: "DESC", TypedValues: true},
+		} {
+			path := opts.BanquetPath
+			res, err := engine.Query(ctx, opts)
+			if err != nil {
+				t.Fatalf("Query %s failed: %v", path, err)
+			}
+			values := map[string][]interface{}{}
+			for _, row := range res.Values {
+				for i, col := range res.Columns {
+					values[col] = append(values[col], row[i].(TypedValue).Value)
+				}
+			}
+			at := values["at"]
+			if len(at) != 2 || at[0] != "2024-01-01T10:00:00Z" || at[1] != int64(1704103200) {
+				t.Errorf("%s: expected stored values of at, got %v", path, at)
+			}
+			if i := slices.Index(res.Columns, "at"); res.ColumnTypes[i] != "DATETIME" {
+				t.Errorf("%s: expected at to be reported as DATETIME, got %v", path, res.ColumnTypes)
+			}
+		}
+	})
+
+	// Arrow results are typed already and ignore the option
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/rows?path=/files.db/files&typed=true&format=arrow", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for Arrow, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,141 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/darianmavgo/banquet/sqlite"
+)
+
+// DefaultValuesLimit is the page size of DistinctValues when none is given.
+const DefaultValuesLimit = 100
+
+// ValuesOptions narrows and pages the result of DistinctValues.
+type ValuesOptions struct {
+	FilterWhere     string // SQL fragment
+	FilterModelJSON string // AgGrid Filter Model JSON; the column's own entry is ignored
+	Search          string // Case-insensitive substring the values must contain
+	SortBy          string // "count" (default, most frequent first) or "value"
+	Offset          int
+	Limit           int // <= 0 uses DefaultValuesLimit
+}
+
+// ValueCount is one distinct value of a column and how many rows hold it.
+type ValueCount struct {
+	Value interface{} `json:"value"`
+	Count int64       `json:"count"`
+}
+
+// DistinctValuesResult is a page of distinct values for a column.
+type DistinctValuesResult struct {
+	Column        string       `json:"column"`
+	Values        []ValueCount `json:"values"`
+	TotalDistinct int          `json:"totalDistinct"`
+	HasMore       bool         `json:"hasMore"`
+	SQL           string       `json:"sql"`
+}
+
+// DistinctValues returns the distinct values of column with their row counts,
+// restricted by the Banquet path's WHERE and the filter model. Following AG Grid
+// set filter semantics, the column's own filter is left out so every choice stays visible.
+func (e *Engine) DistinctValues(ctx context.Context, path, column string, opts ValuesOptions) (*DistinctValuesResult, error) {
+	if column == "" {
+		return nil, fmt.Errorf("column required")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultValuesLimit
+	}
+
+	filterWhere := opts.FilterWhere
+	if opts.FilterModelJSON != "" {
+		var model AgFilterModel
+		if err := json.Unmarshal([]byte(opts.FilterModelJSON), &model); err != nil {
+			return nil, fmt.Errorf("error building filter: %w", err)
+		}
+		delete(model, column)
+		fmWhere, err := buildWhereFromModel(model)
+		if err != nil {
+			return nil, fmt.Errorf("error building filter: %w", err)
+		}
+		if fmWhere != "" {
+			if filterWhere != "" {
+				filterWhere = fmt.Sprintf("(%s) AND (%s)", filterWhere, fmWhere)
+			} else {
+				filterWhere = fmWhere
+			}
+		}
+	}
+
+	pq, err := e.prepareQuery(ctx, QueryOptions{
+		BanquetPath: path,
+		FilterWhere: filterWhere,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cols, err := tableColumns(ctx, pq.db, pq.bq.Table)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if !containsString(cols, column) {
+		return nil, fmt.Errorf("no such column: %s", column)
+	}
+
+	col := sqlite.QuoteIdentifier(column)
+	where := pq.bq.Where
+	if opts.Search != "" {
+		search := fmt.Sprintf("CAST(%s AS TEXT) LIKE %s ESCAPE '\\'", col, quoteLiteral("%"+escapeLike(opts.Search)+"%"))
+		if where != "" {
+			where = fmt.Sprintf("(%s) AND (%s)", where, search)
+		} else {
+			where = search
+		}
+	}
+
+	from := sqlite.QuoteIdentifier(pq.bq.Table)
+	if where != "" {
+		from += " WHERE " + where
+	}
+
+	order := "count DESC, value"
+	if opts.SortBy == "value" {
+		order = "value"
+	}
+
+	result := &DistinctValuesResult{Column: column, Values: make([]ValueCount, 0)}
+	result.SQL = fmt.Sprintf("SELECT %s AS value, COUNT(*) AS count FROM %s GROUP BY %s ORDER BY %s LIMIT %d OFFSET %d",
+		col, from, col, order, opts.Limit+1, opts.Offset)
+
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM %s GROUP BY %s)", from, col)
+	if err := pq.db.QueryRowContext(ctx, countQuery).Scan(&result.TotalDistinct); err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	rows, err := pq.db.QueryContext(ctx, result.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var vc ValueCount
+		if err := rows.Scan(&vc.Value, &vc.Count); err != nil {
+			continue
+		}
+		if b, ok := vc.Value.([]byte); ok {
+			vc.Value = string(b)
+		}
+		result.Values = append(result.Values, vc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	if len(result.Values) > opts.Limit {
+		result.Values = result.Values[:opts.Limit]
+		result.HasMore = true
+	}
+	return result, nil
+}
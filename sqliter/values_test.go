@@ -0,0 +1,83 @@
+package sqliter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestDistinctValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "tickets.db"), `
+		CREATE TABLE tickets (id INTEGER PRIMARY KEY, status TEXT, team TEXT);
+		INSERT INTO tickets (status, team) VALUES
+			('open', 'red'), ('open', 'red'), ('open', 'blue'),
+			('closed', 'red'), ('pending', 'blue'), (NULL, 'blue');
+	`)
+
+	engine := NewEngine(&Config{ServeFolder: tmpDir})
+	defer engine.CloseAll()
+	ctx := context.Background()
+
+	res, err := engine.DistinctValues(ctx, "/tickets.db/tickets", "status", ValuesOptions{})
+	if err != nil {
+		t.Fatalf("DistinctValues failed: %v", err)
+	}
+	if res.TotalDistinct != 4 || len(res.Values) != 4 {
+		t.Fatalf("Expected 4 distinct values including NULL, got %+v", res)
+	}
+	if res.Values[0].Value != "open" || res.Values[0].Count != 3 {
+		t.Errorf("Expected most frequent value first, got %+v", res.Values[0])
+	}
+
+	t.Run("Filter model excludes own column", func(t *testing.T) {
+		model := `{"team":{"filterType":"text","type":"equals","filter":"red"},"status":{"filterType":"set","values":["open"]}}`
+		res, err := engine.DistinctValues(ctx, "/tickets.db/tickets", "status", ValuesOptions{FilterModelJSON: model})
+		if err != nil {
+			t.Fatalf("DistinctValues failed: %v", err)
+		}
+		// Red team has open (2) and closed (1); the status set filter itself is ignored
+		if res.TotalDistinct != 2 || res.Values[0].Count != 2 {
+			t.Errorf("Unexpected values for red team: %+v", res.Values)
+		}
+	})
+
+	t.Run("Search and paging", func(t *testing.T) {
+		res, err := engine.DistinctValues(ctx, "/tickets.db/tickets", "status", ValuesOptions{Search: "en", SortBy: "value", Limit: 1})
+		if err != nil {
+			t.Fatalf("DistinctValues failed: %v", err)
+		}
+		if res.TotalDistinct != 2 || len(res.Values) != 1 || res.Values[0].Value != "open" || !res.HasMore {
+			t.Errorf("Expected first page with 'open' of 2 matches, got %+v", res)
+		}
+	})
+
+	t.Run("Unknown column", func(t *testing.T) {
+		if _, err := engine.DistinctValues(ctx, "/tickets.db/tickets", "nope", ValuesOptions{}); err == nil {
+			t.Errorf("Expected error for unknown column")
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		server := NewServer(&Config{ServeFolder: tmpDir})
+		q := url.Values{"path": {"/tickets.db/tickets"}, "column": {"team"}}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/sqliter/values?"+q.Encode(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body DistinctValuesResult
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body.TotalDistinct != 2 {
+			t.Errorf("Expected 2 teams, got %+v", body)
+		}
+	})
+}
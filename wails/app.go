@@ -22,7 +22,13 @@ type App struct {
 // NewApp creates a new App application struct
 func NewApp() *App {
 	cfg := &sqliter.Config{
-		ServeFolder: "/",
+		ServeFolder:      "/",
+		QueryHistory:     true,
+		ResultCacheBytes: sqliter.DefaultResultCacheBytes,
+	}
+	// Keep the catalog with the user's settings rather than the working directory
+	if dir, err := os.UserConfigDir(); err == nil {
+		cfg.CatalogPath = filepath.Join(dir, "sqliter", "catalog.db")
 	}
 	return &App{
 		engine: sqliter.NewEngine(cfg),
@@ -78,25 +84,194 @@ func expandHome(path string) string {
 	return path
 }
 
+// expandAttach expands the tilde in the paths of attached databases.
+func expandAttach(attach map[string]string) map[string]string {
+	for alias, path := range attach {
+		attach[alias] = expandHome(path)
+	}
+	return attach
+}
+
 func (a *App) ListFiles(dir string) ([]sqliter.FileEntry, error) {
 	dir = expandHome(dir)
 	// Use app context or TODO. App context is cancelled on shutdown.
 	return a.engine.ListFiles(a.ctx, dir)
 }
 
+// FindDatabases searches recursively for databases whose file, table or column names match.
+func (a *App) FindDatabases(opts sqliter.SearchOptions) ([]sqliter.DatabaseMatch, error) {
+	opts.Dir = expandHome(opts.Dir)
+	return a.engine.FindDatabases(a.ctx, opts)
+}
+
 func (a *App) ListTables(db string) ([]sqliter.TableInfo, error) {
 	db = expandHome(db)
 	return a.engine.ListTables(a.ctx, db)
 }
 
+// Query runs a Banquet query. Setting opts.GroupBy switches it to aggregation mode,
+// which also serves AG Grid server-side row grouping via opts.Drilldown.
 func (a *App) Query(opts sqliter.QueryOptions) (*sqliter.QueryResult, error) {
 	start := time.Now()
 	opts.BanquetPath = expandHome(opts.BanquetPath)
+	opts.Attach = expandAttach(opts.Attach)
 	res, err := a.engine.Query(a.ctx, opts)
 	fmt.Printf("[Wails.App.Query] Took %v\n", time.Since(start))
 	return res, err
 }
 
+// DistinctValues returns the distinct values of a column with counts, for set filters.
+func (a *App) DistinctValues(path, column string, opts sqliter.ValuesOptions) (*sqliter.DistinctValuesResult, error) {
+	return a.engine.DistinctValues(a.ctx, expandHome(path), column, opts)
+}
+
+// ProfileTable computes per-column statistics for the table selected by a Banquet path.
+func (a *App) ProfileTable(path string, opts sqliter.ProfileOptions) (*sqliter.TableProfile, error) {
+	return a.engine.ProfileTable(a.ctx, expandHome(path), opts)
+}
+
+// SearchDatabase looks for a value in the text columns of every table of a database.
+func (a *App) SearchDatabase(opts sqliter.SearchDatabaseOptions) (*sqliter.SearchDatabaseResult, error) {
+	opts.DB = expandHome(opts.DB)
+	return a.engine.SearchDatabase(a.ctx, opts)
+}
+
+// GetFTSIndex returns the FTS5 index of a table, or nil if it has none.
+func (a *App) GetFTSIndex(db, table string) (*sqliter.FTSIndex, error) {
+	return a.engine.GetFTSIndex(a.ctx, expandHome(db), table)
+}
+
+// CreateFTSIndex indexes the given columns (all text columns if empty) of a table with FTS5.
+func (a *App) CreateFTSIndex(db, table string, columns []string) (*sqliter.FTSIndex, error) {
+	return a.engine.CreateFTSIndex(a.ctx, expandHome(db), table, columns)
+}
+
+// RebuildFTSIndex repopulates the FTS5 index of a table.
+func (a *App) RebuildFTSIndex(db, table string) error {
+	return a.engine.RebuildFTSIndex(a.ctx, expandHome(db), table)
+}
+
+// DropFTSIndex removes the FTS5 index of a table.
+func (a *App) DropFTSIndex(db, table string) error {
+	return a.engine.DropFTSIndex(a.ctx, expandHome(db), table)
+}
+
+// Pivot spreads the distinct values of a pivot column into aggregated result columns.
+func (a *App) Pivot(opts sqliter.PivotOptions) (*sqliter.QueryResult, error) {
+	opts.BanquetPath = expandHome(opts.BanquetPath)
+	return a.engine.Pivot(a.ctx, opts)
+}
+
+// TimeSeries buckets rows by a date/time column for charting.
+func (a *App) TimeSeries(opts sqliter.TimeSeriesOptions) (*sqliter.TimeSeriesResult, error) {
+	opts.BanquetPath = expandHome(opts.BanquetPath)
+	return a.engine.TimeSeries(a.ctx, opts)
+}
+
+// Related returns Banquet paths to the parent and child rows of a row via foreign keys.
+func (a *App) Related(path string, rowid int64) (*sqliter.RelatedResult, error) {
+	return a.engine.Related(a.ctx, expandHome(path), rowid)
+}
+
+// SchemaGraph returns the entity-relationship graph of a database.
+func (a *App) SchemaGraph(db string, opts sqliter.SchemaGraphOptions) (*sqliter.SchemaGraph, error) {
+	return a.engine.SchemaGraph(a.ctx, expandHome(db), opts)
+}
+
+// DiffSchema compares the schemas of two databases.
+func (a *App) DiffSchema(dbA, dbB string, opts sqliter.DiffSchemaOptions) (*sqliter.SchemaDiff, error) {
+	return a.engine.DiffSchema(a.ctx, expandHome(dbA), expandHome(dbB), opts)
+}
+
+// ExecuteSQL runs a statement from the SQL console, with optional attached databases.
+func (a *App) ExecuteSQL(opts sqliter.SQLOptions) (*sqliter.QueryResult, error) {
+	opts.DB = expandHome(opts.DB)
+	opts.Attach = expandAttach(opts.Attach)
+	return a.engine.ExecuteSQL(a.ctx, opts)
+}
+
+// ListSavedQueries returns the saved queries whose name or description contains search.
+func (a *App) ListSavedQueries(search string) ([]sqliter.SavedQuery, error) {
+	catalog, err := a.engine.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	return catalog.List(a.ctx, search)
+}
+
+// GetSavedQuery returns a saved query by ID, or by name if id is 0.
+func (a *App) GetSavedQuery(id int64, name string) (*sqliter.SavedQuery, error) {
+	catalog, err := a.engine.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Get(a.ctx, id, name)
+}
+
+// SaveQuery creates (ID 0) or updates a saved query.
+func (a *App) SaveQuery(q sqliter.SavedQuery) (*sqliter.SavedQuery, error) {
+	catalog, err := a.engine.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Save(a.ctx, q)
+}
+
+// DeleteSavedQuery removes a saved query.
+func (a *App) DeleteSavedQuery(id int64) error {
+	catalog, err := a.engine.Catalog()
+	if err != nil {
+		return err
+	}
+	return catalog.Delete(a.ctx, id)
+}
+
+// ListHistory returns recorded queries matching the filter.
+func (a *App) ListHistory(f sqliter.HistoryFilter) ([]sqliter.HistoryEntry, error) {
+	a.engine.FlushHistory()
+	catalog, err := a.engine.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	return catalog.ListHistory(a.ctx, f)
+}
+
+// PinHistory pins or unpins a recorded query so it is kept.
+func (a *App) PinHistory(id int64, pinned bool) error {
+	catalog, err := a.engine.Catalog()
+	if err != nil {
+		return err
+	}
+	return catalog.PinHistory(a.ctx, id, pinned)
+}
+
+// ReplayHistory runs a recorded query again.
+func (a *App) ReplayHistory(id int64) (*sqliter.QueryResult, error) {
+	return a.engine.ReplayHistory(a.ctx, id)
+}
+
+// Explain returns the query plans of a Banquet query without running it.
+func (a *App) Explain(opts sqliter.QueryOptions) (*sqliter.ExplainResult, error) {
+	opts.BanquetPath = expandHome(opts.BanquetPath)
+	opts.Attach = expandAttach(opts.Attach)
+	return a.engine.Explain(a.ctx, opts)
+}
+
+// AdviseIndexes suggests indexes for a database from the queries run so far.
+func (a *App) AdviseIndexes(db string) ([]sqliter.IndexSuggestion, error) {
+	return a.engine.AdviseIndexes(a.ctx, expandHome(db))
+}
+
+// ApplyIndex creates a suggested index.
+func (a *App) ApplyIndex(db, table string, columns []string) (*sqliter.IndexSuggestion, error) {
+	return a.engine.ApplyIndex(a.ctx, expandHome(db), table, columns)
+}
+
+// CacheStats reports the query result cache statistics.
+func (a *App) CacheStats() sqliter.CacheStats {
+	return a.engine.CacheStats()
+}
+
 // StreamQuery starts a streaming query using the provided QueryID.
 // The actual data is pumped via Wails Events.
 func (a *App) StreamQuery(opts sqliter.QueryOptions, queryID string) error {
@@ -111,9 +286,10 @@ func (a *App) StreamQuery(opts sqliter.QueryOptions, queryID string) error {
 			}
 		}()
 
-		err := a.engine.QueryStream(a.ctx, opts, func(chunk sqliter.QueryResultChunk) {
+		err := a.engine.QueryStream(a.ctx, opts, func(chunk sqliter.QueryResultChunk) error {
 			// Emit chunk
 			runtime.EventsEmit(a.ctx, fmt.Sprintf("sqliter:stream:chunk:%s", queryID), chunk)
+			return nil
 		})
 
 		if err != nil {